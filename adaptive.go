@@ -0,0 +1,181 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Concurrency limit adjusted by the -latencyTarget controller
+type adaptiveLimit struct {
+	mu sync.Mutex
+	// Requests allowed in flight and the requests in flight now
+	limit    int
+	inFlight int
+	// Most requests the threads can have in flight
+	ceiling int
+	// Doubles the limit every interval until the target is missed or the ceiling is reached, then adds one
+	slowStart bool
+	// Closed and replaced when a slot frees up or the limit changes, to wake the waiting threads
+	changed chan struct{}
+}
+
+// Concurrency and results of one -latencyTarget control interval
+type adaptiveStep struct {
+	ElapsedTime         float64 `json:"elapsedSec"`
+	Concurrency         int     `json:"concurrency"`
+	RequestsPerSecond   float64 `json:"requestsPerSecond"`
+	AverageResponseTime float64 `json:"averageResponseTimeMs"`
+	// The concurrency was still doubling to find the target
+	SlowStart bool `json:"slowStart"`
+	// Enough threads were still running at the end of the interval to fill the concurrency, so the load was not
+	// dropping
+	Steady bool `json:"steady"`
+}
+
+// Function to create a limit that starts at one request in flight and grows up to the ceiling
+func newAdaptiveLimit(ceiling int) *adaptiveLimit {
+	return &adaptiveLimit{limit: 1, ceiling: ceiling, slowStart: true, changed: make(chan struct{})}
+}
+
+// Function to wait for a request slot under the current limit.  Returns false when the context is done first.
+func (limit *adaptiveLimit) acquire(ctx context.Context) bool {
+	for {
+		limit.mu.Lock()
+		if limit.inFlight < limit.limit {
+			limit.inFlight++
+			limit.mu.Unlock()
+			return true
+		}
+		changed := limit.changed
+		limit.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Function to free a request slot
+func (limit *adaptiveLimit) release() {
+	limit.mu.Lock()
+	limit.inFlight--
+	limit.wakeLocked()
+	limit.mu.Unlock()
+}
+
+// Function to wake the waiting threads.  The caller must hold the limit mutex.
+func (limit *adaptiveLimit) wakeLocked() {
+	close(limit.changed)
+	limit.changed = make(chan struct{})
+}
+
+// Function to adjust the limit after an interval, AIMD style.  A met target doubles the limit during the slow start
+// and adds one after it, and a missed target cuts the limit by a quarter.  Returns the limit the interval ran at and
+// whether it was in the slow start.
+func (limit *adaptiveLimit) adjust(metTarget bool) (int, bool) {
+	limit.mu.Lock()
+	defer limit.mu.Unlock()
+	current, slowStart := limit.limit, limit.slowStart
+	if metTarget {
+		if limit.slowStart {
+			limit.limit *= 2
+		} else {
+			limit.limit++
+		}
+	} else {
+		limit.slowStart = false
+		limit.limit = limit.limit * 3 / 4
+	}
+	limit.limit = min(max(limit.limit, 1), limit.ceiling)
+	if limit.limit == limit.ceiling {
+		limit.slowStart = false
+	}
+	limit.wakeLocked()
+	return current, slowStart
+}
+
+// Function to adjust the concurrency every interval to keep the average response time of the requests completed in
+// the interval at the target until done is closed.  Records each interval in the steps.
+func controlConcurrency(mu *sync.Mutex, stats *testStats, limit *adaptiveLimit, target float64,
+	interval time.Duration, done <-chan struct{}, finished chan<- struct{}) {
+	defer close(finished)
+
+	startTime := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	counted := 0
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		// The response times recorded since the last interval.  No completed requests means they all took longer
+		// than the interval, which misses the target.
+		mu.Lock()
+		recent := stats.responseTimes[counted:]
+		counted = len(stats.responseTimes)
+		total := 0.0
+		for _, responseTime := range recent {
+			total += responseTime
+		}
+		mu.Unlock()
+		average := 0.0
+		if len(recent) > 0 {
+			average = total / float64(len(recent))
+		}
+		concurrency, slowStart := limit.adjust(len(recent) > 0 && average <= target)
+
+		mu.Lock()
+		stats.adaptiveSteps = append(stats.adaptiveSteps, adaptiveStep{
+			ElapsedTime:         time.Since(startTime).Seconds(),
+			Concurrency:         concurrency,
+			RequestsPerSecond:   float64(len(recent)) / interval.Seconds(),
+			AverageResponseTime: average,
+			SlowStart:           slowStart,
+			Steady:              stats.steady.threads-stats.steady.finishedThreads >= concurrency,
+		})
+		mu.Unlock()
+	}
+}
+
+// Function to find the sustained throughput at the target, the average requests per second of the steady intervals
+// past the slow start that met the target, and the highest concurrency that met it
+func sustainedThroughput(steps []adaptiveStep, target float64) (float64, int) {
+	total, count, knee := 0.0, 0, 0
+	for _, step := range steps {
+		if step.Steady && !step.SlowStart && step.RequestsPerSecond > 0 && step.AverageResponseTime <= target {
+			total += step.RequestsPerSecond
+			count++
+			knee = max(knee, step.Concurrency)
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return total / float64(count), knee
+}
+
+// Function to print the concurrency and results of each control interval.  The slow start intervals and the
+// intervals with too few threads left to fill the concurrency are marked since they are left out of the sustained
+// throughput.
+func printAdaptiveSteps(steps []adaptiveStep) {
+	fmt.Println("Concurrency timeline:")
+	for _, step := range steps {
+		note := ""
+		if step.SlowStart {
+			note = " - Slow start"
+		} else if !step.Steady {
+			note = " - Threads finishing"
+		}
+		fmt.Printf("  %7.1f s - Concurrency: %4d - Requests per second: %9.2f - Average: %8.2f ms%s\n",
+			step.ElapsedTime, step.Concurrency, step.RequestsPerSecond, step.AverageResponseTime, note)
+	}
+}
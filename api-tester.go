@@ -4,14 +4,25 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 )
 
@@ -19,73 +30,1330 @@ func printHelp() {
 	fmt.Println("Usage:")
 	fmt.Println("  api-tester [URL] [arguments]")
 	fmt.Println("Required arguments:")
-	fmt.Println("  [URL]                   - Server URL.")
+	fmt.Println("  [URL]                       - Server URL.  Optional with -requestsFromStdin, -har, or -target.")
 	fmt.Println("Optional Arguments:")
-	fmt.Println("  -totalCalls [value]     - Total number of calls across all threads. Default is 10000.")
-	fmt.Println("  -numThreads [value]     - Number of threads. Default is 12.")
-	fmt.Println("  -sleepTime [value]      - Sleep time in milliseconds between calls within a thread. Default is 0.")
-	fmt.Println("  -requestTimeOut [value] - HTTP request timeout in milliseconds. Default is 10000.")
-	fmt.Println("  -connectTimeOut [value] - HTTP request timeout in milliseconds. Default is 20000.")
-	fmt.Println("  -reuseConnects          - Add the request 'Connection: keep-alive' header.")
-	fmt.Println("  -keepConnectsOpen       - Force a new connection with every request (not advised).")
+	fmt.Println("  -totalCalls [value]         - Total number of calls across all threads.  Each thread makes the same")
+	fmt.Println("                                number, and the first threads one more for the remainder. Default is 10000.")
+	fmt.Println("  -numThreads [value]         - Number of threads. Default is 12.")
+	fmt.Println("  -sleepTime [value]          - Sleep time in milliseconds between calls within a thread. Default is 0.")
+	fmt.Println("  -maxRPSPerThread [value]    - Most requests per second each thread starts, like 2.5, and report the rate")
+	fmt.Println("                                each thread achieved.  Default is no limit.")
+	fmt.Println("  -burst [value]              - Number of requests each thread sends back to back before pausing for")
+	fmt.Println("                                -burstPause, instead of sleeping between every call.")
+	fmt.Println("  -burstPause [value]         - Pause time in milliseconds after each -burst. Default is 1000.")
+	fmt.Println("  -jitterClock                - Measure how late the -sleepTime, -burstPause, and -maxRPSPerThread waits")
+	fmt.Println("                                wake up, and take the delay off the next wait.  Reports the average and")
+	fmt.Println("                                max delay and whether it grew, a sign the tester cannot keep up.")
+	fmt.Println("  -requestTimeOut [value]     - HTTP request timeout in milliseconds. Default is 10000.")
+	fmt.Println("  -connectTimeOut [value]     - HTTP request timeout in milliseconds. Default is 20000.")
+	fmt.Println("  -responseHeaderTimeout [ms] - Time in milliseconds to wait for the response headers after sending")
+	fmt.Println("                                the request, and report the requests that timed out. Default is 0, none.")
+	fmt.Println("  -maxRedirects [value]       - Most redirects a request follows before it fails as too many redirects.")
+	fmt.Println("                                Use 0 to fail every redirected request. Default is 10.")
+	fmt.Println("  -method [value]             - HTTP request method. Default is GET, or POST with -bodyDir.")
+	fmt.Println("  -oauthTokenURL [value]      - OAuth2 token endpoint.  Fetches a client credentials bearer token before the")
+	fmt.Println("                                test and refreshes it near expiry or when a request returns 401.")
+	fmt.Println("  -oauthClientID [value]      - OAuth2 client ID.")
+	fmt.Println("  -oauthClientSecret [value]  - OAuth2 client secret.")
+	fmt.Println("  -oauthScopes [value]        - Space-separated OAuth2 scopes to request.")
+	fmt.Println("  -tokenCommand [value]       - Command that prints a bearer token, like \"gcloud auth print-access-token\".")
+	fmt.Println("                                Runs before the test and again near the -tokenTTL or on a 401.")
+	fmt.Println("  -tokenTTL [value]           - Lifetime in seconds of a -tokenCommand token. Default is 0, until a 401.")
+	fmt.Println("  -preRequestCommand [value]  - Command run before each URL or -target request that prints \"Name: value\"")
+	fmt.Println("                                headers, then optionally a blank line and the request body, like a")
+	fmt.Println("                                signature.  Gets APITESTER_METHOD, APITESTER_URL, APITESTER_THREAD, and")
+	fmt.Println("                                APITESTER_ITERATION.  A process per request limits the request rate.")
+	fmt.Println("  -preRequestTimeout [value]  - Timeout in milliseconds of a -preRequestCommand run. Default is 5000.")
+	fmt.Println("  -preRequestCache [value]    - Reuse a -preRequestCommand output for this many milliseconds. Default is")
+	fmt.Println("                                0, run it for every request.")
+	fmt.Println("  -rampUp [value]             - Time in milliseconds over which the thread starts are spread. Default is 0.")
+	fmt.Println("  -startJitter [value]        - Most random time in milliseconds added to each thread start so the threads")
+	fmt.Println("                                do not send their requests in lockstep. Default is 0.")
+	fmt.Println("  -warmup [value]             - Number of unrecorded warmup calls each thread makes first. Default is 0.")
+	fmt.Println("  -cacheWarm                  - Send each request of the test once, unrecorded, to fill a CDN or proxy")
+	fmt.Println("                                cache, then measure the cached responses and report the cache hit rate.")
+	fmt.Println("  -cacheHeader [value]        - Response header with the cache result, counted as a hit when it has")
+	fmt.Println("                                \"HIT\".  Reports the cache hit rate. Default is X-Cache.")
+	fmt.Println("  -warmupDuration [value]     - Seconds after the ramp-up that the threads make unrecorded warmup calls.")
+	fmt.Println("                                The test time and requests per second start after it. Default is 0.")
+	fmt.Println("  -streams [value]            - Number of concurrent requests each thread sends per iteration.  With an")
+	fmt.Println("                                https URL they are multiplexed as HTTP/2 streams on one connection.")
+	fmt.Println("  -prewarm                    - Open a connection per thread before the timed test so the first requests")
+	fmt.Println("                                reuse them.  Requires -reuseConnects.")
+	fmt.Println("  -reuseConnects              - Add the request 'Connection: keep-alive' header.")
+	fmt.Println("  -rotateConnAfter [value]    - Close the connection after every this many requests of a thread so the")
+	fmt.Println("                                next request opens a new one.  Use with -reuseConnects.")
+	fmt.Println("  -requestsPerConn [value]    - Close each connection after this many requests on it and report when the")
+	fmt.Println("                                server closed connections itself.  Requires -reuseConnects.")
+	fmt.Println("  -connectionLifetime [value] - Close and replace each connection once it is this many seconds old,")
+	fmt.Println("                                whatever its idle time, like a load balancer age limit.  Requires")
+	fmt.Println("                                -reuseConnects.")
+	fmt.Println("  -maxInflight [value]        - Most requests in flight at once across all the threads, whatever the")
+	fmt.Println("                                number of threads.  Default is no limit.")
+	fmt.Println("  -latencyTarget [value]      - Adjust the requests in flight, up to -numThreads, to keep the average")
+	fmt.Println("                                response time near the target in milliseconds, and report the concurrency")
+	fmt.Println("                                over time and the sustained throughput at the target.")
+	fmt.Println("  -adaptInterval [value]      - Time in milliseconds between the -latencyTarget adjustments. Default is 1000.")
+	fmt.Println("  -compareKeepAlive           - Run the test with -reuseConnects and again without, and print the two")
+	fmt.Println("                                summaries side by side.")
+	fmt.Println("  -compareTargets [url] [url] - Run the same test against one URL, then the other, like a canary and the")
+	fmt.Println("                                stable endpoint, and print the two summaries side by side.  Replaces [URL].")
+	fmt.Println("  -coordinator [host:port]    - Listen for -workers workers, send each the test with its share of")
+	fmt.Println("                                -totalCalls, print their combined progress, and print the merged summary.")
+	fmt.Println("  -workers [value]            - Number of workers the -coordinator waits for. Default is 1.")
+	fmt.Println("  -worker [host:port]         - Run the tests of the coordinator at the address.  Takes only -workerToken.")
+	fmt.Println("  -workerToken [value]        - Secret shared by the -coordinator and its workers, required by both.  A")
+	fmt.Println("                                worker runs only the tests sent with its token, and never with the")
+	fmt.Println("                                -tokenCommand, -preRequestCommand, or -validatorCommand flags.  The")
+	fmt.Println("                                connection is plain TCP, so use it only on a trusted network.")
+	fmt.Println("  -keepConnectsOpen           - Force a new connection with every request (not advised).")
+	fmt.Println("  -correlationId              - Send a unique UUID with each request and print it in the request line.")
+	fmt.Println("  -correlationHeader [value]  - Header for the -correlationId UUID. Default is X-Correlation-Id.")
+	fmt.Println("  -verifyEcho [value]         - Fail the responses that do not echo the request header value in the")
+	fmt.Println("                                response header, like \"X-Request-Id=X-Request-Id\", and count the crossed")
+	fmt.Println("                                responses.  Without -correlationId a UUID is sent in the request header.")
+	fmt.Println("  -checkOnly                  - Send a single request, print the request and response in full, and")
+	fmt.Println("                                print PASS or FAIL.  Ignores -totalCalls and -numThreads.  Exits with 1")
+	fmt.Println("                                if it fails.")
+	fmt.Println("  -dumpFailuresOnly           - Only print the per-request lines of the failed requests, with their full")
+	fmt.Println("                                details.  The statistics still include every request.")
+	fmt.Println("  -color [value]              - Color the per-request results: auto, always, or never.  Default is auto,")
+	fmt.Println("                                which colors a terminal but not a file or pipe.  With always, output")
+	fmt.Println("                                redirected to a file or pipe contains the ANSI escape codes.")
+	fmt.Println("  -statusEveryN [value]       - Print a one-line running summary every this many completed requests")
+	fmt.Println("                                instead of the per-request lines.  Failures are still printed with")
+	fmt.Println("                                -dumpFailuresOnly.")
+	fmt.Println("  -sortOutput                 - Hold the per-request lines until the test ends and print them in thread")
+	fmt.Println("                                and iteration order.  Every result is kept in memory until then, so use")
+	fmt.Println("                                it for small debugging runs.")
+	fmt.Println("  -runtimeStats               - Report the tester's own GC runs, GC pause time, and peak heap.")
+	fmt.Println("  -verbose                    - Print the per-request details, like the redirect chain.")
+	fmt.Println("  -retries [value]            - Number of times to retry a request that returns a 5xx status. Default is 0.")
+	fmt.Println("  -retryPolicy [value]        - Wait between retries, \"fixed\" or \"exponential\" (full jitter). Default is fixed.")
+	fmt.Println("  -retryBackoff [value]       - Base wait time in milliseconds between retries. Default is 100.")
+	fmt.Println("  -retryMaxBackoff [value]    - Maximum wait time in milliseconds for exponential retries. Default is 10000.")
+	fmt.Println("  -saveBodies [dir]           - Save response bodies to files named by thread and iteration in the directory.")
+	fmt.Println("  -saveBodiesCount [value]    - Number of response bodies to save with -saveBodies. Default is 10.")
+	fmt.Println("  -connectionsOnly            - Only open and close connections, without sending requests, and measure the")
+	fmt.Println("                                TCP connect and TLS handshake time.")
+	fmt.Println("  -verifyTLS                  - Verify the server certificates.  A failure reports the certificate subject,")
+	fmt.Println("                                issuer, and expiry.")
+	fmt.Println("  -caCert [file]              - Verify the server certificates against the CA certificates in the PEM file.")
+	fmt.Println("                                Can be repeated.  Implies -verifyTLS.")
+	fmt.Println("  -systemCerts                - Trust the system CA certificates too with -caCert.")
+	fmt.Println("  -noTLSResume                - Do a full TLS handshake on every new connection instead of resuming the")
+	fmt.Println("                                TLS session.")
+	fmt.Println("  -rebuildOnErrors [value]    - Replace the HTTP client connection pool after this many consecutive")
+	fmt.Println("                                transport errors.  Default is 0, never.")
+	fmt.Println("  -hostsFile [file]           - Rotate the connections through the backend IPs in the file, one per line,")
+	fmt.Println("                                keeping the URL Host header, and report the statistics per backend.")
+	fmt.Println("  -localPortRange [start-end] - Bind the connections to the local ports in the range in rotation, like")
+	fmt.Println("                                20000-29999, and report when they run out.")
+	fmt.Println("  -localAddresses [value]     - Comma-separated local IP addresses to bind the connections to in rotation.")
+	fmt.Println("  -dnsCache [value]           - Cache the host name lookups for the TTL in seconds instead of resolving the")
+	fmt.Println("                                host name for every new connection, and report the cache hit rate.")
+	fmt.Println("  -probeTimeout [value]       - Timeout in milliseconds of the connection probe to the URL host before the")
+	fmt.Println("                                test.  The test does not start if the probe fails. Default is 5000.")
+	fmt.Println("  -ignoreProbe                - Start the test even if the connection probe fails.")
+	fmt.Println("  -waitForReady [url]         - Poll the URL before the test until it returns 200, to start right after a")
+	fmt.Println("                                deployment.  Exits with 1 if it is not ready in time.")
+	fmt.Println("  -readyTimeout [value]       - Seconds to wait for -waitForReady. Default is 60.")
+	fmt.Println("  -readyInterval [value]      - Time in milliseconds between the -waitForReady polls. Default is 1000.")
+	fmt.Println("  -ipVersion [value]          - IP version to connect with, \"4\", \"6\", or \"auto\". Default is auto.")
+	fmt.Println("  -target [file]              - Send requests to the weighted endpoints in the file, one per line:")
+	fmt.Println("                                METHOD URL [weight=N] [status=N] [timeout=ms] [header=\"Name: value\"]...")
+	fmt.Println("                                [body=text|@file]")
+	fmt.Println("                                A file ending in .json is an array of {\"url\", \"weight\", \"method\",")
+	fmt.Println("                                \"body\", \"headers\", \"status\", \"timeoutMs\"} objects.  Cannot be used")
+	fmt.Println("                                with -queryFile or the request body flags, like -bodyDir or -jsonBody.")
+	fmt.Println("                                Relative URLs are resolved against the [URL].")
+	fmt.Println("  -queryFile [file]           - Append the query strings in the file, one per line, to the URL round-robin.")
+	fmt.Println("  -bodyDir [dir]              - Send the files in the directory as the request bodies, one file per request.")
+	fmt.Println("  -bodyDirRandom              - Choose the -bodyDir file at random instead of round-robin.")
+	fmt.Println("  -bodySizeMin [value]        - Smallest random filler request body in bytes. Default is 0.")
+	fmt.Println("  -bodySizeMax [value]        - Send a filler request body of a random size up to this many bytes with")
+	fmt.Println("                                each request, and report the correlation of the size with the response time.")
+	fmt.Println("  -jsonBody [json|@file]      - Send the JSON or file contents with each request, with the application/json")
+	fmt.Println("                                content type.  Method defaults to POST.")
+	fmt.Println("  -protoBody [json|@file]     - Encode the JSON message or file contents as protobuf for each request, with")
+	fmt.Println("                                the application/x-protobuf content type.  {{thread}} and {{iteration}}")
+	fmt.Println("                                placeholders encode it for every request.  Method defaults to POST.")
+	fmt.Println("  -protoDescriptor [file]     - Binary FileDescriptorSet with the -protoBody message type, like from")
+	fmt.Println("                                \"protoc --include_imports --descriptor_set_out\".")
+	fmt.Println("  -protoMessage [value]       - Full name of the -protoBody message type, like \"shop.v1.Order\".")
+	fmt.Println("  -chainURL [value]           - URL of a second request sent after each request with a value from the")
+	fmt.Println("                                first response in place of {{value}}, like a GET of the resource a POST")
+	fmt.Println("                                created.  Relative URLs resolve against the first URL.  Reports each step.")
+	fmt.Println("  -chainMethod [value]        - HTTP method of the -chainURL request. Default is GET.")
+	fmt.Println("  -chainBody [value]          - Body of the -chainURL request with the {{value}} placeholder.")
+	fmt.Println("  -bodyFromResponse [value]   - Where the -chainURL value comes from, \"header:Name\" or \"json:$.path\".")
+	fmt.Println("                                Default is header:Location.")
+	fmt.Println("  -repeatBody [text|@file]    - Repeat the text or file contents to make a -repeatSize request body for")
+	fmt.Println("                                each request.  Method defaults to POST.")
+	fmt.Println("  -repeatSize [value]         - Size in bytes of the -repeatBody request body. Default is 1048576.")
+	fmt.Println("  -randomizeHeaders           - Write the request headers in a random order for every request instead of")
+	fmt.Println("                                sorted by name, to find order-sensitive servers.  Plain http only, so")
+	fmt.Println("                                https URLs are an error and https lines on stdin are skipped.")
+	fmt.Println("  -expect100                  - Send the request bodies with \"Expect: 100-continue\", waiting up to a")
+	fmt.Println("                                second for the server to ask for the body, and report the wait.")
+	fmt.Println("  -jsonPatch [file]           - Send the JSON Patch in the file with each request, with the")
+	fmt.Println("                                application/json-patch+json content type.  Method defaults to PATCH.")
+	fmt.Println("  -mergePatch [file]          - Send the JSON Merge Patch in the file with each request, with the")
+	fmt.Println("                                application/merge-patch+json content type.  Method defaults to PATCH.")
+	fmt.Println("  -maxBodySize [value]        - Maximum response body bytes to read.  Larger bodies are truncated and counted.")
+	fmt.Println("  -readRate [value]           - Read the response bodies at no more than this many bytes per second, like a")
+	fmt.Println("                                slow client, and report how long the connections were held.")
+	fmt.Println("  -noBodyRead                 - Drain the response bodies with reused buffers without looking at them, for")
+	fmt.Println("                                the highest request rate.  Cannot be used with the flags that need the body.")
+	fmt.Println("  -validateJSON               - Count responses with a body that is not valid JSON as failures.")
+	fmt.Println("  -assertJSON [value]         - Count JSON responses that fail the JSONPath check, like \"$.count > 0\" or")
+	fmt.Println("                                \"$.items[0].status == 'ok'\", as failures.  Repeatable.")
+	fmt.Println("  -expectHeader [value]       - Count responses without the header value, like \"Cache-Control: no-store\",")
+	fmt.Println("                                as failures.  A \"*\" value only checks that the header is present.")
+	fmt.Println("                                Repeatable.")
+	fmt.Println("  -success [value]            - Count responses that do not satisfy the expression as failures, like")
+	fmt.Println("                                \"status==200 && latency<500ms && body.contains('ok')\".  Fields are status,")
+	fmt.Println("                                latency, bytes, and retries.  Default only counts \"status==2xx\".")
+	fmt.Println("  -validatorCommand [value]   - Command run for each response with the body on stdin that fails the")
+	fmt.Println("                                request with a non-zero exit.  Gets APITESTER_URL, APITESTER_STATUS, and")
+	fmt.Println("                                APITESTER_RESPONSE_TIME.  A process per response takes milliseconds, so")
+	fmt.Println("                                the request rate is at most -validatorWorkers divided by the run time.")
+	fmt.Println("  -validatorTimeout [value]   - Timeout in milliseconds of a -validatorCommand run. Default is 5000.")
+	fmt.Println("  -validatorWorkers [value]   - Most -validatorCommand runs at once. Default is the number of CPUs.")
+	fmt.Println("  -apdexTarget [value]        - Apdex satisfied response time target in milliseconds for the Apdex score.")
+	fmt.Println("  -slaAlert [value]           - Print an alert when the rolling p99 response time exceeds this many milliseconds.")
+	fmt.Println("  -slaWindow [value]          - Number of recent requests in the -slaAlert rolling p99. Default is 1000.")
+	fmt.Println("  -slaAbort                   - Stop the test on the first -slaAlert alert.")
+	fmt.Println("  -stopOnFirstError           - Stop the test on the first failed request and print its details.  Exits")
+	fmt.Println("                                with 1 if a request failed.")
+	fmt.Println("  -maxErrors [value]          - Stop the test when more than this many requests have failed.  Exits with 1")
+	fmt.Println("                                if it stopped.")
+	fmt.Println("  -slo [value]                - Service level objective to PASS or FAIL, like \"p99<200ms\", \"avg<=50ms\",")
+	fmt.Println("                                \"max<1s\", or \"errors<1%\".  Repeatable.  Exits with 1 if any fail.")
+	fmt.Println("  -slaFile [file]             - File of named SLAs to PASS or FAIL in a table, one per line, like")
+	fmt.Println("                                \"checkout p99<300ms, errors<1%\".  Exits with 1 if any fail.  A name")
+	fmt.Println("                                followed by url=prefix, like \"checkout url=/checkout p99<300ms\", checks")
+	fmt.Println("                                only the requests with the URL or path prefix.")
+	fmt.Println("  -reportTemplate [file]      - Print the summary with a Go text/template file instead of the built-in")
+	fmt.Println("                                format.  The template is executed with the JSON summary Result fields.")
+	fmt.Println("  -output [value]             - Summary format, \"text\" or \"csv\" for a header and a single row of the")
+	fmt.Println("                                label, time, count, error rate, p50, p95, p99, and throughput.")
+	fmt.Println("  -summaryCSV [file]          - Append the \"csv\" summary row to the file, with the header when the file")
+	fmt.Println("                                is new, to collect several runs in one spreadsheet.")
+	fmt.Println("  -label [value]              - Run label added to the JSON summary and the per-request, metrics, and")
+	fmt.Println("                                exemplar outputs to tell apart the results of several load generators.")
+	fmt.Println("  -csvOut [file]              - Write a CSV row for every request to the file.  A file name ending in")
+	fmt.Println("                                \".gz\" is compressed with gzip, also for -jsonlOut.")
+	fmt.Println("  -jsonlOut [file]            - Write a JSON object for every request to the file, one per line, as the")
+	fmt.Println("                                requests finish.  Use /dev/fd/N to stream to an open file descriptor.")
+	fmt.Println("  -trace                      - Time the DNS, connect, TLS, send, wait, and transfer phases of every request")
+	fmt.Println("                                and print the share of the latency each took.  Adds some overhead.")
+	fmt.Println("  -traceOut [file]            - Write the -trace phase times to the file as folded stacks in microseconds")
+	fmt.Println("                                for flame graph tools.")
+	fmt.Println("  -rpsTimeline                - Print the number of requests completed in each second of the test.")
+	fmt.Println("  -rpsTimelineFile [file]     - Write the -rpsTimeline to the file as CSV lines of the second and count.")
+	fmt.Println("  -metricsFile [file]         - Append a timestamped CSV line with the request rate and p50, p95, and p99")
+	fmt.Println("                                response times of each -interval to the file.")
+	fmt.Println("  -interval [value]           - Interval in milliseconds between the -metricsFile lines. Default is 1000.")
+	fmt.Println("  -influxOut [file]           - Append the summary to the file in InfluxDB line protocol, tagged with the")
+	fmt.Println("                                label, host, and URL, with the latency percentiles and throughput.")
+	fmt.Println("  -influxUrl [url]            - Send the InfluxDB lines to the write URL, like")
+	fmt.Println("                                \"http://localhost:8086/api/v2/write?org=my-org&bucket=my-bucket\".")
+	fmt.Println("  -influxToken [value]        - InfluxDB API token sent with the -influxUrl writes.")
+	fmt.Println("  -influxInterval             - Also write an InfluxDB line with the request rate and percentiles of each")
+	fmt.Println("                                -interval during the test.")
+	fmt.Println("  -summaryInterval [value]    - Print a summary block of the test so far, with the percentiles, status")
+	fmt.Println("                                codes, and errors, every this many seconds. Default is 0, none.")
+	fmt.Println("  -exemplarsOut [file]        - Write a random sample of the individual requests to the file as JSON Lines")
+	fmt.Println("                                with their start time, response time, status, and correlation ID.")
+	fmt.Println("  -exemplarCount [value]      - Number of requests sampled for -exemplarsOut. Default is 100.")
+	fmt.Println("  -jsonOut [file]             - Write the JSON test summary to the file.  With -compareKeepAlive or")
+	fmt.Println("                                -compareTargets, the summaries of both runs and the deltas.")
+	fmt.Println("  -requestsFromStdin          - Read newline-delimited URLs or JSON request objects from stdin and")
+	fmt.Println("                                issue them across the threads as they arrive.  Ignores -totalCalls.")
+	fmt.Println("                                JSON format: {\"method\":\"GET\",\"url\":\"...\",\"headers\":{},\"body\":\"\"}")
+	fmt.Println("  -har [file]                 - Replay the requests of a HAR capture across the threads.  Ignores")
+	fmt.Println("                                -totalCalls without -replayLoop.")
+	fmt.Println("  -replayTiming               - Send the -har requests at their captured start times instead of as fast")
+	fmt.Println("                                as possible, and report how late they were sent.")
+	fmt.Println("  -replayLoop                 - Replay the -har requests in a loop until -totalCalls requests are sent, and")
+	fmt.Println("                                report the statistics for each URL over all the loops.")
+	fmt.Println("  -timeScale [value]          - Multiplier for the -replayTiming gaps, like 0.5 for twice as fast.")
+	fmt.Println("                                Default is 1.")
+	fmt.Println("Environment variables, overridden by the same flag on the command line.  The command line turns a")
+	fmt.Println("boolean variable off with -flag=false, like -reuseConnects=false:")
+	fmt.Printf("  %-27s - Server URL when there is no [URL] argument.\n", envURL)
+	for _, env := range envFlags {
+		fmt.Printf("  %-27s - Same as %s.\n", env.name, env.flag)
+	}
 	fmt.Println("Help:")
-	fmt.Println("  -? or --help            - Display this help message.")
+	fmt.Println("  -? or --help                - Display this help message.")
+}
+
+// Function to get the index of the value of the flag at index i.  Exits with an error when the flag is the last
+// argument and has no value.
+func nextArg(i int) int {
+	if i+1 >= len(os.Args) {
+		fmt.Printf("Error: Missing value for %s.\n", os.Args[i])
+		printHelp()
+		os.Exit(1)
+	}
+	return i + 1
+}
+
+// Function to split the total calls across the threads.  Every thread makes totalCalls / numThreads calls and the
+// first totalCalls % numThreads threads make one more for the remainder, so the counts differ by at most one and add
+// up to totalCalls.
+func callsPerThread(totalCalls int, numThreads int) []int {
+	calls := make([]int, numThreads)
+	for i := range calls {
+		calls[i] = totalCalls / numThreads
+		if i < totalCalls%numThreads {
+			calls[i]++
+		}
+	}
+	return calls
+}
+
+// Function to describe the split of the calls, like "2 threads x 4 calls + 1 thread x 3 calls"
+func describeCallSplit(calls []int) string {
+	var parts []string
+	for start := 0; start < len(calls); {
+		end := start
+		for end < len(calls) && calls[end] == calls[start] {
+			end++
+		}
+		threads, callNoun := "threads", "calls"
+		if end-start == 1 {
+			threads = "thread"
+		}
+		if calls[start] == 1 {
+			callNoun = "call"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s x %d %s", end-start, threads, calls[start], callNoun))
+		start = end
+	}
+	return strings.Join(parts, " + ")
+}
+
+// Function to find the value of a flag in the arguments before they are parsed.  Exits with the error when the
+// value is missing, like nextArg.
+func argValue(args []string, flag string) (string, bool) {
+	for i, arg := range args {
+		if arg == flag {
+			if i+1 >= len(args) {
+				fmt.Printf("Error: Missing value for %s.\n", flag)
+				os.Exit(1)
+			}
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// Settings shared by all the request threads
+type testConfig struct {
+	// Sleep time between calls in a thead
+	sleepTime time.Duration
+	// Requests per second cap of each thread, zero for no cap
+	maxRPSPerThread float64
+	// Leaves all the connection requests open
+	keepConnectsOpen bool
+	// Reuse the HTTP connections
+	reuseConnects bool
+	// Number of times to retry a request that returns a 5xx status
+	retries int
+	// Retry backoff policy, "fixed" or "exponential"
+	retryPolicy string
+	// Base wait time between retries
+	retryBackoff time.Duration
+	// Maximum wait time between retries for the exponential policy
+	retryMaxBackoff time.Duration
+	// Directory to save response bodies in, empty to not save them
+	saveBodiesDir string
+	// Number of response bodies to save
+	saveBodiesCount int64
+	// Fail responses with a body that is not valid JSON
+	validateJSON bool
+	// Response headers that every response must have
+	expectHeaders []headerCheck
+	// JSONPath checks that every response body must pass
+	jsonAssertions []jsonAssertion
+	// Success predicate counted for every response, and whether responses that do not satisfy it fail
+	success         *successPredicate
+	successRequired bool
+	// Maximum response body bytes read, zero for no limit
+	maxBodySize int64
+	// Drain the response bodies with pooled buffers
+	noBodyRead bool
+	// Send the request bodies with "Expect: 100-continue"
+	expect100 bool
+	// Bytes per second the response bodies are read at, zero for no limit
+	readRate int64
+	// Request bodies loaded from -bodyDir
+	bodyFiles []bodyFile
+	// Templated -protoBody encoded for every request, nil for no body or a body encoded once
+	protoBody *protoBody
+	// Second request of the -chainURL flow, nil when not enabled
+	chain *responseChain
+	// Choose the body file at random instead of round-robin
+	bodyRandom bool
+	// Random request body size range and the filler the bodies are cut from, nil without -bodySizeMax
+	bodySizeMin int
+	bodySizeMax int
+	bodyFiller  []byte
+	// Content-Type header of the request bodies, empty to not set it
+	contentType string
+	// Query strings from -queryFile appended to the URL round-robin
+	queries []string
+	// Time over which the thread starts are spread
+	rampUp time.Duration
+	// Most random time added to each thread start
+	startJitter time.Duration
+	// Number of calls each thread makes before its results are recorded
+	warmupCalls int
+	// Send each request once before the recorded calls to fill the cache
+	cacheWarm bool
+	// Time until which the threads make unrecorded warmup calls, zero for no warmup time
+	warmupEnd time.Time
+	// Number of threads making requests
+	numThreads int
+	// Default timeout of a -target request
+	requestTimeout time.Duration
+	// Time the transport waits for the response headers, zero for no limit
+	responseHeaderTimeout time.Duration
+	// Number of concurrent requests each thread sends per iteration
+	streams int
+	// Number of requests after which a thread closes its connection, zero to not rotate
+	rotateConnAfter int
+	// Number of requests each thread sends back to back before the burst pause, zero to use the sleep time
+	burst      int
+	burstPause time.Duration
+	// Measure the scheduling delay of the waits between the requests and take it off the next wait
+	jitterClock bool
+	// Print the per-request details, like redirect chains
+	verbose bool
+	// Print the requests completed in each second in the summary
+	rpsTimeline bool
+	// Time the phases of every request
+	trace bool
+	// Only print the per-request lines of the failed requests
+	failuresOnly bool
+	// Color the per-request results
+	color bool
+	// Completed requests between the -statusEveryN lines, zero for the per-request lines
+	statusEvery int
+	// Per-request results held back for -sortOutput, nil to print them as they finish
+	sortedOutput *sortedOutput
+	// Record the backend address of each request for the -hostsFile statistics
+	perBackend bool
+	// Header to send a unique request ID in, empty to not send one
+	correlationHeader string
+	// Response header that must echo a request header, nil without -verifyEcho
+	echo *echoCheck
+	// Function to stop the test early with the reason
+	abort context.CancelCauseFunc
+	// OAuth2 bearer token applied to every request, nil when not enabled
+	token *bearerToken
+	// Command run before the requests to generate headers and a body, nil when not enabled
+	preRequest *preRequestHook
+	// Command that checks each response, nil without -validatorCommand
+	validator *responseValidator
+	// Endpoints from the -target file and the weighted selection over them
+	targets      []Target
+	targetPicker *targetPicker
 }
 
-// Function to make the GET request and measure response time
-func fetchData(wg *sync.WaitGroup, mu *sync.Mutex, httpClient *http.Client, responseTimes *[]float64, url string,
-	sleepTime time.Duration, keepConnectsOpen bool, reuseConnects bool, threadID int, numCalls int) {
+// Statistics collected by all the request threads.  Guarded by the output mutex.
+type testStats struct {
+	responseTimes []float64
+	// Sorted copy of the response times, set by summarize
+	sortedTimes []float64
+	// Window between the ramp-up and warmup and the first thread finishing
+	steady steadyWindow
+	// Response body bytes of every recorded request
+	responseSizes []float64
+	// -label run label added to the structured outputs
+	label string
+	// Sampled requests for the -exemplarsOut file, nil when not enabled
+	exemplars *exemplarReservoir
+	// Total -trace phase times in milliseconds and the number of traced requests
+	phaseTotals [phaseCount]float64
+	traced      int
+	// -expect100 requests, the ones the server sent a "100 Continue" for, and their total wait in milliseconds
+	expectContinue    int
+	got100            int
+	continueWaitTotal float64
+	// Completed requests in each second for -rpsTimeline, nil when not enabled
+	timeline *throughputTimeline
+	// Response times of the current -metricsFile interval
+	interval intervalWindow
+	// Rolling p99 SLA alert, nil when not enabled
+	sla *slaAlert
+	// Function to stop the test on the first failed request, nil when not enabled
+	stopOnFailure func(error)
+	// Failed requests allowed before the test stops and the function to stop it, nil when not enabled
+	maxErrors    int
+	stopOnErrors func(error)
+	// Response time statistics for each status code, zero for requests that failed without a response
+	statusCodes map[int]*LatencySummary
+	// Per-request CSV output, nil when not enabled
+	csvOut *csv.Writer
+	// Per-request JSON Lines output, nil when not enabled
+	jsonlOut *jsonlWriter
+	// TLS handshakes of the connections opened by the requests
+	handshakes handshakeStats
+	// Requests that returned a non-5xx response on the first attempt
+	firstTrySuccesses int
+	// Requests that returned a non-5xx response after one or more retries
+	retriedSuccesses int
+	// Total number of retry attempts made
+	retries int
+	// Requests that failed with an error or failed validation
+	failures int
+	// Requests cancelled by the test stopping early.  These are not failures and have no response time.
+	cancelled int
+	// Failed requests grouped by the normalized error message
+	errorGroups map[string]*errorGroup
+	// Responses that satisfied the success predicate
+	satisfied int
+	// Per-connection request counts for -requestsPerConn, nil when not enabled
+	connLimit *connRequestLimit
+	// Connection ages for -connectionLifetime, nil when not enabled
+	connAge *connLifetime
+	// Concurrency limit of the -latencyTarget mode and its control intervals, nil when not enabled
+	adaptive      *adaptiveLimit
+	adaptiveSteps []adaptiveStep
+	// Slots for the -maxInflight requests, nil when not enabled
+	inflightSlots chan struct{}
+	// Requests that closed their connection for -rotateConnAfter
+	rotations int
+	// Requests sent again with a refreshed bearer token after a 401 response
+	authRetries int
+	// Responses that failed the -validateJSON check
+	invalidJSON int
+	// Responses that failed an -expectHeader check
+	headerFailures int
+	// Responses that failed an -assertJSON check
+	jsonAssertFailures int
+	// Responses that the -validatorCommand rejected
+	validatorFailures int
+	// Responses without the -verifyEcho response header and with the value of another request
+	echoMissing    int
+	echoMismatches int
+	// Responses with a body larger than -maxBodySize
+	truncated int
+	// Time from sending each -readRate request to finishing reading its body
+	holdTimes LatencySummary
+	// Requests that failed to bind a local port or address
+	localPortFailures int
+	// Failed requests that never got a connection
+	connectFailures int
+	// Requests that timed out waiting for the response headers
+	headerTimeouts int
+	// Requests that were redirected and requests that exceeded the redirect limit
+	redirected       int
+	tooManyRedirects int
+	// Failed requests for each -bodyDir file
+	bodyFileFailures map[string]int
+	// Request body size and response time of each -bodySizeMax request
+	bodySizes []bodySizeSample
+	// Response time statistics for the first request of each -burst and for the rest of the burst
+	burstFirst LatencySummary
+	burstRest  LatencySummary
+	// Response time statistics for each -hostsFile backend address
+	backendStats map[string]*LatencySummary
+	// Response time statistics for each -streams stream number
+	streamStats map[int]*LatencySummary
+	// Response time statistics for each -queryFile query string
+	queryStats map[string]*LatencySummary
+	// Response time statistics and failed requests for each -replayLoop URL, nil when not enabled
+	urlStats    map[string]*LatencySummary
+	urlFailures map[string]int
+	// Response time statistics and failed requests for each -target file target
+	targetStats    map[*Target]*LatencySummary
+	targetFailures map[*Target]int
+	// Timed out requests for each -target file target
+	targetTimeouts map[*Target]int
+	// Number of response bodies claimed for saving.  Updated atomically so it is not guarded by the mutex.
+	bodiesSaved atomic.Int64
+	// Completed requests between the -statusEveryN lines and the running total of the response times
+	statusEvery       int
+	responseTimeTotal float64
+	// Requests per second achieved by each thread with -maxRPSPerThread, nil when not enabled
+	threadRates *threadRates
+	// Delays of the waits between the requests for -jitterClock, nil when not enabled
+	schedulingDelays *schedulingDelays
+	// Cache results of the responses for -cacheHeader, nil when not enabled
+	cache *cacheStats
+	// Statistics of the -chainURL steps, nil when not enabled
+	chain *chainStats
+	// Requests of the endpoints the -slaFile SLAs are scoped to
+	slaScopes []*slaScope
+	// Number of requests currently in flight and the peak reached.  Updated atomically.
+	inFlight     atomic.Int64
+	peakInFlight atomic.Int64
+}
+
+// Function to make the requests and measure response time
+func fetchData(ctx context.Context, wg *sync.WaitGroup, mu *sync.Mutex, httpClient *http.Client, stats *testStats,
+	url string, method string, cfg *testConfig, threadID int, numCalls int) {
 	defer wg.Done()
-	status := ""
 
 	// Create the request structure for the httpClient
-	request, err := http.NewRequest("GET", url, nil)
+	request, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		fmt.Printf("Error:  Request creation failed for thread %2d: %v\n", threadID, err)
+		return
+	}
+	setConnectionHeader(request, cfg.reuseConnects)
+	if cfg.contentType != "" {
+		request.Header.Set("Content-Type", cfg.contentType)
+	}
+	baseQuery := request.URL.RawQuery
+
+	// Spread the thread starts over the ramp-up time, then make the unrecorded warmup calls
+	if !rampUpDelay(cfg, ctx.Done(), threadID, cfg.numThreads) {
+		return
+	}
+	warmupCalls := cfg.warmupCalls
+	if cfg.cacheWarm {
+		warmupCalls = max(warmupCalls, cacheWarmCalls(cfg, numCalls))
+	}
+	for i := 0; i < warmupCalls && ctx.Err() == nil; i++ {
+		if warmupRequest, prepared, err := prepareRequest(ctx, request, baseQuery, cfg, threadID, i); err == nil {
+			doRequest(httpClient, warmupRequest, cfg, stats, nil)
+			prepared.release()
+		}
+	}
+	// The warmup time ends at the same time for all the threads.  Paced like the measured calls so the server is
+	// already at the load of the test.
+	for i := warmupCalls; time.Now().Before(cfg.warmupEnd) && ctx.Err() == nil; i++ {
+		if warmupRequest, prepared, err := prepareRequest(ctx, request, baseQuery, cfg, threadID, i); err == nil {
+			doRequest(httpClient, warmupRequest, cfg, stats, nil)
+			prepared.release()
+		}
+		if cfg.sleepTime > 0 {
+			time.Sleep(min(cfg.sleepTime, time.Until(cfg.warmupEnd)))
+		}
+	}
+	mu.Lock()
+	stats.threadReady()
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		stats.threadFinished()
+		mu.Unlock()
+	}()
+
+	sleep := time.Sleep
+	var clock *jitterClock
+	if cfg.jitterClock {
+		clock = &jitterClock{}
+		sleep = clock.sleep
+		defer func() {
+			mu.Lock()
+			stats.schedulingDelays.add(clock)
+			mu.Unlock()
+		}()
+	}
+	var pacer *threadPacer
+	if cfg.maxRPSPerThread > 0 {
+		pacer = newThreadPacer(cfg.maxRPSPerThread)
+		pacer.clock = clock
+		defer func() {
+			if rate, ok := pacer.rate(); ok {
+				mu.Lock()
+				stats.threadRates.rates = append(stats.threadRates.rates, rate)
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < numCalls && ctx.Err() == nil; i++ {
+		if pacer != nil && !pacer.wait(ctx.Done()) {
+			break
+		}
+		if cfg.streams > 1 {
+			fetchStreams(ctx, mu, httpClient, stats, request, baseQuery, cfg, threadID, i)
+		} else {
+			fetchOnce(ctx, mu, httpClient, stats, request, baseQuery, cfg, threadID, i, 0)
+		}
+		// The default zero sleep time goes straight to the next request, without the sleep or -jitterClock call
+		if cfg.burst == 0 && cfg.sleepTime > 0 {
+			sleep(cfg.sleepTime)
+		} else if cfg.burst > 0 && (i+1)%cfg.burst == 0 {
+			// The requests of a burst are sent back to back with a pause after the last one
+			sleep(cfg.burstPause)
+		}
+	}
+}
+
+// Function to make and record one request of a thread iteration.  Stream is the 1-based -streams number, or zero.
+func fetchOnce(ctx context.Context, mu *sync.Mutex, httpClient *http.Client, stats *testStats, request *http.Request,
+	baseQuery string, cfg *testConfig, threadID int, iteration int, stream int) {
+	request, prepared, err := prepareRequest(ctx, request, baseQuery, cfg, threadID, iteration)
 	if err != nil {
+		mu.Lock()
 		fmt.Printf("Error:  Request creation failed for thread %2d: %v\n", threadID, err)
+		mu.Unlock()
+		return
+	}
+	defer prepared.release()
+
+	// Every -rotateConnAfter request closes its connection so the next request opens a new one
+	rotate := cfg.rotateConnAfter > 0 && (iteration+1)%cfg.rotateConnAfter == 0
+	if rotate {
+		request.Header.Set("Connection", "close")
+	} else if cfg.rotateConnAfter > 0 {
+		setConnectionHeader(request, cfg.reuseConnects)
+	}
+
+	saved := stats.claimBodyBuffer(cfg)
+	// A -bodyFromResponse JSON value is read from the response body
+	body := saved
+	if body == nil && cfg.chain != nil && cfg.chain.needsBody {
+		body = &bytes.Buffer{}
+	}
+	result := doRequest(httpClient, request, cfg, stats, body)
+	result.ThreadID = threadID
+	result.Iteration = iteration
+	result.Stream = stream
+	if cfg.chain != nil {
+		result.Step = 1
+	}
+	result.rotated = rotate
+	if cfg.burst > 0 {
+		result.Burst = iteration%cfg.burst + 1
+	}
+	result.Query = prepared.Query
+	result.BodyFile = prepared.BodyFile
+	result.RequestBytes = prepared.RequestBytes
+	result.randomBody = prepared.randomBody
+	result.target = prepared.target
+	if result.target != nil {
+		result.Target = result.target.Name()
+		checkStatus(&result, result.target.ExpectStatus)
+	}
+	saveBody(cfg, saved, threadID, iteration)
+
+	mu.Lock()
+	printResult(&result, cfg)
+	stats.record(&result)
+	mu.Unlock()
+
+	if cfg.chain != nil && !result.Cancelled {
+		cfg.chain.follow(ctx, mu, httpClient, stats, cfg, threadID, iteration, &result, body)
+	}
+}
+
+// Function to set the request for a thread iteration from the targets, query file, or body directory.  Returns the
+// request to make, which is a new request for a target, and the result fields describing it.
+func prepareRequest(ctx context.Context, request *http.Request, baseQuery string, cfg *testConfig, threadID int,
+	iteration int) (*http.Request, requestResult, error) {
+	var prepared requestResult
+
+	if cfg.targetPicker != nil {
+		prepared.target = &cfg.targets[cfg.targetPicker.pick()]
+		// Each target request has its own timeout, the target timeout or the global request timeout
+		timeout := cfg.requestTimeout
+		if prepared.target.Timeout > 0 {
+			timeout = prepared.target.Timeout
+		}
+		targetCtx, cancel := context.WithTimeout(ctx, timeout)
+		prepared.cancel = cancel
+		targetRequest, err := prepared.target.newRequest(targetCtx, cfg)
+		if err == nil && cfg.preRequest != nil {
+			err = cfg.preRequest.apply(targetRequest, threadID, iteration)
+		}
+		return targetRequest, prepared, err
+	}
+
+	if len(cfg.queries) > 0 {
+		prepared.Query = cfg.queries[roundRobin(threadID, iteration, len(cfg.queries))]
+		request.URL.RawQuery = joinQuery(baseQuery, prepared.Query)
+	}
+	if len(cfg.bodyFiles) > 0 {
+		file := chooseBodyFile(cfg, threadID, iteration)
+		setRequestBody(request, file.data)
+		prepared.BodyFile = file.name
+	}
+	if cfg.bodyFiller != nil {
+		prepared.RequestBytes = setRandomSizeBody(request, cfg)
+		prepared.randomBody = true
+	}
+	if cfg.protoBody != nil {
+		data, err := cfg.protoBody.encode(threadID, iteration)
+		if err != nil {
+			return request, prepared, fmt.Errorf("protobuf encoding failed: %w", err)
+		}
+		setRequestBody(request, data)
+	}
+	if cfg.preRequest != nil {
+		if err := cfg.preRequest.apply(request, threadID, iteration); err != nil {
+			return request, prepared, err
+		}
 	}
+	return request, prepared, nil
+}
 
+// Function to set the Connection header for the connection reuse setting
+func setConnectionHeader(request *http.Request, reuseConnects bool) {
 	if reuseConnects {
-		request.Header.Add("Connection", "keep-alive")
+		request.Header.Set("Connection", "keep-alive")
 	} else {
-		request.Header.Add("Connection", "close")
+		request.Header.Set("Connection", "close")
+	}
+}
+
+// Function to make a request, retrying 5xx responses, and measure the response time of the final attempt in
+// milliseconds.  The final response body is kept in body if it is not nil.
+func doRequest(httpClient *http.Client, request *http.Request, cfg *testConfig, stats *testStats,
+	body *bytes.Buffer) requestResult {
+	result := requestResult{URL: request.URL.String()}
+	var backend *string
+	if cfg.perBackend {
+		backend = &result.Backend
+	}
+	request = withHandshakeTrace(request, &stats.handshakes, backend)
+	// Retries keep the ID so the server logs show all the attempts of the request
+	if cfg.correlationHeader != "" {
+		result.CorrelationID = newCorrelationID()
+		request.Header.Set(cfg.correlationHeader, result.CorrelationID)
+	}
+	if body == nil && (cfg.validateJSON || cfg.success.needsBody || len(cfg.jsonAssertions) > 0 || cfg.validator != nil) {
+		body = &bytes.Buffer{}
 	}
 
-	for i := 0; i < numCalls; i++ {
-		startTime := time.Now()
-		// Make the http or https call
-		resp, err := httpClient.Do(request)
-		endTime := time.Now()
+	authRetried := false
+	for attempt := 0; ; {
+		token := ""
+		if cfg.token != nil {
+			var err error
+			if token, err = cfg.token.get(); err != nil {
+				result.err = fmt.Errorf("token fetch failed: %w", err)
+				break
+			}
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
 
-		// Use microseconds to get float value and convert to milliseconds
-		responseTime := (float64)(endTime.Sub(startTime).Microseconds()) / 1000
+		if !stats.acquireSlot(request.Context()) {
+			result.err = request.Context().Err()
+			break
+		}
+		stats.startRequest()
+		if stats.connLimit != nil {
+			var use connUse
+			doAttempt(httpClient, stats.connLimit.withTrace(request, &use), cfg, body, &result)
+			stats.connLimit.done(&use, &result)
+		} else if stats.connAge != nil {
+			var use connUse
+			doAttempt(httpClient, stats.connAge.withTrace(request, &use), cfg, body, &result)
+			stats.connAge.done(&use, &result)
+		} else {
+			doAttempt(httpClient, request, cfg, body, &result)
+		}
+		stats.inFlight.Add(-1)
+		stats.releaseSlot()
+		result.Retries = attempt
 
-		if resp != nil {
-			status = resp.Status
-			if !keepConnectsOpen {
-				// Must read the body to close the session.  Dumping it to null out.
-				// Not reading the body will keep the connection occupied until the connection timeout.
-				_, err = io.Copy(io.Discard, resp.Body)
-				err = resp.Body.Close()
+		// A rejected token is refreshed once and the request is sent again with the new token
+		if result.err == nil && result.StatusCode == http.StatusUnauthorized && cfg.token != nil && !authRetried {
+			authRetried = true
+			result.authRetried = true
+			if _, err := cfg.token.refresh(token); err != nil {
+				result.err = fmt.Errorf("token refresh failed: %w", err)
+				break
 			}
+			resetRequestBody(request)
+			continue
 		}
 
-		mu.Lock()
-		if err != nil {
-			fmt.Printf("Thread %2d.%-6d - Request failed: %v - Response time: %.2f ms\n", threadID, i, err, responseTime)
+		if result.err != nil || result.StatusCode < 500 || attempt >= cfg.retries || request.Context().Err() != nil {
+			break
+		}
+		if !waitRetry(request.Context(), retryDelay(cfg, attempt)) {
+			break
+		}
+		resetRequestBody(request)
+		attempt++
+	}
+
+	if result.err == nil && cfg.echo != nil {
+		result.err = cfg.echo.verify(request.Header, result.header)
+	}
+	if result.err == nil {
+		result.err = validateResponse(cfg, &result, body)
+	}
+	if result.err != nil {
+		result.Error = result.err.Error()
+		// Requests interrupted by the test stopping are not server failures.  Only a request the context aborted
+		// before a response is cancelled, so a response that failed, like a 5xx or a failed validation, is still a
+		// failure when the test stops right after it.  A test stopped with a cause can fail the request with the
+		// cause instead of context.Canceled.  A request timeout is a failure.
+		result.Cancelled = result.StatusCode == 0 && (errors.Is(result.err, context.Canceled) ||
+			isCancelCause(request.Context(), result.err))
+	}
+	return result
+}
+
+// Function to check if the error is the cause the context was cancelled with
+func isCancelCause(ctx context.Context, err error) bool {
+	cause := context.Cause(ctx)
+	return cause != nil && errors.Is(err, cause)
+}
+
+// Function to give a request with a body a fresh reader for the next attempt
+func resetRequestBody(request *http.Request) {
+	if request.GetBody != nil {
+		request.Body, _ = request.GetBody()
+	}
+}
+
+// Function to make a single request and measure the response time in milliseconds.  Sets the status code, response
+// time, redirect chain, and error of the result.
+func doAttempt(httpClient *http.Client, request *http.Request, cfg *testConfig, body *bytes.Buffer,
+	result *requestResult) {
+	var redirects []string
+	request = withRedirectChain(request, &redirects)
+	var phases *phaseTrace
+	if cfg.trace {
+		phases = &phaseTrace{}
+		request = phases.withTrace(request)
+	}
+	connected := false
+	request = trackConnection(request, &connected)
+	var expect *continueTrace
+	if cfg.expect100 && hasRequestBody(request) {
+		expect = &continueTrace{}
+		request = expect.withTrace(request)
+	}
+
+	statusCode := 0
+	startTime := time.Now()
+	// Make the http or https call
+	resp, err := httpClient.Do(request)
+	responseTime := millisecondsSince(startTime)
+	if err != nil {
+		err = describeTLSError(err)
+	}
+
+	// A redirect error returns the last response with the body already closed
+	truncated := false
+	var bodySize int64
+	if resp != nil && err == nil {
+		statusCode = resp.StatusCode
+		result.serverClose = resp.Close
+		result.header = resp.Header
+		var reader io.Reader = resp.Body
+		if cfg.readRate > 0 {
+			reader = newThrottledReader(resp.Body, cfg.readRate)
+		}
+		if cfg.maxBodySize > 0 {
+			// Read one byte past the cap to detect a body that exceeded it
+			reader = io.LimitReader(reader, cfg.maxBodySize+1)
+		}
+		if body != nil {
+			// Keep the body for saving or validation.  The response time is already measured so reading it has no
+			// timing impact.
+			body.Reset()
+			bodySize, err = io.Copy(body, reader)
+			err = resp.Body.Close()
+		} else if cfg.noBodyRead && !cfg.keepConnectsOpen {
+			bodySize, err = drainBody(reader)
+			err = resp.Body.Close()
+		} else if !cfg.keepConnectsOpen {
+			// Must read the body to close the session.  Dumping it to null out.
+			// Not reading the body will keep the connection occupied until the connection timeout.
+			bodySize, err = io.Copy(io.Discard, reader)
+			err = resp.Body.Close()
+		}
+		if cfg.maxBodySize > 0 && bodySize > cfg.maxBodySize {
+			truncated = true
+			if body != nil {
+				body.Truncate(int(cfg.maxBodySize))
+			}
+		}
+	} else if resp != nil {
+		statusCode = resp.StatusCode
+	}
+
+	if phases != nil {
+		result.phases = phases.finish(startTime)
+		result.traced = true
+	}
+	if expect != nil {
+		result.expectContinue = true
+		result.got100, result.continueWait = expect.finish()
+	}
+	// The connection is held until the throttled body is read
+	if cfg.readRate > 0 {
+		result.HoldTime = millisecondsSince(startTime)
+	}
+	result.StatusCode = statusCode
+	result.ResponseTime = responseTime
+	result.Redirects = redirects
+	result.Truncated = truncated
+	result.Bytes = bodySize
+	result.startTime = startTime
+	result.err = err
+	result.connectFailed = err != nil && !connected
+}
+
+// Function to get a buffer for the response body if it is one of the first saveBodiesCount responses.  Returns nil
+// when the body should not be saved.
+func (stats *testStats) claimBodyBuffer(cfg *testConfig) *bytes.Buffer {
+	if cfg.saveBodiesDir == "" || stats.bodiesSaved.Load() >= cfg.saveBodiesCount {
+		return nil
+	}
+	if stats.bodiesSaved.Add(1) > cfg.saveBodiesCount {
+		return nil
+	}
+	return &bytes.Buffer{}
+}
+
+// Function to write a saved response body to a file named by the thread and iteration
+func saveBody(cfg *testConfig, body *bytes.Buffer, threadID int, iteration int) {
+	if body == nil {
+		return
+	}
+	fileName := filepath.Join(cfg.saveBodiesDir, fmt.Sprintf("thread%02d-%06d.body", threadID, iteration))
+	if err := os.WriteFile(fileName, body.Bytes(), 0644); err != nil {
+		fmt.Printf("Error: Saving the response body to \"%s\" failed: %v\n", fileName, err)
+	}
+}
+
+// Function to count a request as in flight and track the peak concurrency
+func (stats *testStats) startRequest() {
+	current := stats.inFlight.Add(1)
+	for {
+		peak := stats.peakInFlight.Load()
+		if current <= peak || stats.peakInFlight.CompareAndSwap(peak, current) {
+			return
+		}
+	}
+}
+
+// Function to wait for a -maxInflight or -latencyTarget slot.  Returns false when the context is done first.
+func (stats *testStats) acquireSlot(ctx context.Context) bool {
+	if stats.adaptive != nil {
+		return stats.adaptive.acquire(ctx)
+	}
+	if stats.inflightSlots == nil {
+		return true
+	}
+	select {
+	case stats.inflightSlots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Function to free a -maxInflight or -latencyTarget slot
+func (stats *testStats) releaseSlot() {
+	if stats.adaptive != nil {
+		stats.adaptive.release()
+	} else if stats.inflightSlots != nil {
+		<-stats.inflightSlots
+	}
+}
+
+// Function to add a request result to the statistics.  The caller must hold the output mutex.
+func (stats *testStats) record(result *requestResult) {
+	result.Label = stats.label
+	if result.Cancelled {
+		stats.cancelled++
+		if stats.csvOut != nil {
+			_ = stats.csvOut.Write(result.csvRecord())
+		}
+		if stats.jsonlOut != nil {
+			stats.jsonlOut.write(result)
+		}
+		return
+	}
+
+	stats.responseTimes = append(stats.responseTimes, result.ResponseTime)
+	stats.responseSizes = append(stats.responseSizes, float64(result.Bytes))
+	if result.HoldTime > 0 {
+		stats.holdTimes.add(result.HoldTime)
+	}
+	if result.randomBody {
+		stats.bodySizes = append(stats.bodySizes, bodySizeSample{size: float64(result.RequestBytes),
+			responseTime: result.ResponseTime})
+	}
+	stats.interval.add(result.ResponseTime)
+	if stats.timeline != nil {
+		stats.timeline.add(time.Now())
+	}
+	if stats.cache != nil && result.header != nil {
+		stats.cache.add(result.header)
+	}
+	if result.expectContinue {
+		stats.expectContinue++
+		if result.got100 {
+			stats.got100++
+			stats.continueWaitTotal += result.continueWait
+		}
+	}
+	if result.traced {
+		for phase, phaseTime := range result.phases {
+			stats.phaseTotals[phase] += phaseTime
+		}
+		stats.traced++
+	}
+	if stats.exemplars != nil {
+		stats.exemplars.add(result)
+	}
+	if stats.sla != nil {
+		stats.sla.add(result.ResponseTime)
+	}
+	stats.retries += result.Retries
+	if len(result.Redirects) > 0 {
+		stats.redirected++
+	}
+	if result.Truncated {
+		stats.truncated++
+	}
+	if result.err != nil {
+		stats.failures++
+		if result.connectFailed {
+			stats.connectFailures++
+		}
+		stats.addErrorGroup(result.err.Error())
+		if stats.stopOnFailure != nil {
+			printFirstFailure(result)
+			stats.stopOnFailure(fmt.Errorf("first failed request: %w", result.err))
+			stats.stopOnFailure = nil
+		}
+		if stats.stopOnErrors != nil && stats.failures > stats.maxErrors {
+			stats.stopOnErrors(fmt.Errorf("more than %d failed requests", stats.maxErrors))
+			stats.stopOnErrors = nil
+		}
+		if errors.Is(result.err, errInvalidJSON) {
+			stats.invalidJSON++
+		}
+		if errors.Is(result.err, errEchoMissing) {
+			stats.echoMissing++
+		}
+		if errors.Is(result.err, errEchoMismatch) {
+			stats.echoMismatches++
+		}
+		if errors.Is(result.err, errHeaderMismatch) {
+			stats.headerFailures++
+		}
+		if errors.Is(result.err, errJSONAssertion) {
+			stats.jsonAssertFailures++
+		}
+		if errors.Is(result.err, errValidatorFailed) {
+			stats.validatorFailures++
+		}
+		if errors.Is(result.err, errTooManyRedirects) {
+			stats.tooManyRedirects++
+		}
+		if errors.Is(result.err, errLocalPortsExhausted) {
+			stats.localPortFailures++
+		}
+		if isHeaderTimeout(result.err) {
+			stats.headerTimeouts++
+		}
+		if result.target != nil {
+			if stats.targetFailures == nil {
+				stats.targetFailures = make(map[*Target]int)
+			}
+			stats.targetFailures[result.target]++
+			if isTimeout(result.err) {
+				if stats.targetTimeouts == nil {
+					stats.targetTimeouts = make(map[*Target]int)
+				}
+				stats.targetTimeouts[result.target]++
+			}
+		}
+		if stats.urlFailures != nil {
+			stats.urlFailures[result.URL]++
+		}
+		if result.BodyFile != "" {
+			if stats.bodyFileFailures == nil {
+				stats.bodyFileFailures = make(map[string]int)
+			}
+			stats.bodyFileFailures[result.BodyFile]++
+		}
+	}
+	if result.satisfied {
+		stats.satisfied++
+	}
+	stats.responseTimeTotal += result.ResponseTime
+	if count := len(stats.responseTimes); stats.statusEvery > 0 && count%stats.statusEvery == 0 {
+		fmt.Printf("Status - Requests: %d - Failed: %d (%.2f%%) - Average response time: %.2f ms\n", count,
+			stats.failures, float64(stats.failures)*100/float64(count), stats.responseTimeTotal/float64(count))
+	}
+	if result.rotated {
+		stats.rotations++
+	}
+	if result.authRetried {
+		stats.authRetries++
+	}
+	if result.err == nil && result.StatusCode < 500 {
+		if result.Retries == 0 {
+			stats.firstTrySuccesses++
 		} else {
-			fmt.Printf("Thread %2d.%-6d - Success: %s - Response time: %.2f ms\n", threadID, i, status, responseTime)
+			stats.retriedSuccesses++
 		}
-		*responseTimes = append(*responseTimes, responseTime)
-		mu.Unlock()
+	}
+
+	if stats.statusCodes == nil {
+		stats.statusCodes = make(map[int]*LatencySummary)
+	}
+	summary, ok := stats.statusCodes[result.StatusCode]
+	if !ok {
+		summary = &LatencySummary{MinResponseTime: result.ResponseTime}
+		stats.statusCodes[result.StatusCode] = summary
+	}
+	summary.add(result.ResponseTime)
+
+	if result.target != nil {
+		if stats.targetStats == nil {
+			stats.targetStats = make(map[*Target]*LatencySummary)
+		}
+		targetSummary, ok := stats.targetStats[result.target]
+		if !ok {
+			targetSummary = &LatencySummary{MinResponseTime: result.ResponseTime}
+			stats.targetStats[result.target] = targetSummary
+		}
+		targetSummary.add(result.ResponseTime)
+	}
+
+	for _, scope := range stats.slaScopes {
+		if scope.matches(result.URL) {
+			scope.add(result)
+		}
+	}
+
+	if stats.urlStats != nil {
+		urlSummary, ok := stats.urlStats[result.URL]
+		if !ok {
+			urlSummary = &LatencySummary{MinResponseTime: result.ResponseTime}
+			stats.urlStats[result.URL] = urlSummary
+		}
+		urlSummary.add(result.ResponseTime)
+	}
+
+	if result.Query != "" {
+		if stats.queryStats == nil {
+			stats.queryStats = make(map[string]*LatencySummary)
+		}
+		querySummary, ok := stats.queryStats[result.Query]
+		if !ok {
+			querySummary = &LatencySummary{MinResponseTime: result.ResponseTime}
+			stats.queryStats[result.Query] = querySummary
+		}
+		querySummary.add(result.ResponseTime)
+	}
+
+	if result.Burst == 1 {
+		stats.burstFirst.add(result.ResponseTime)
+	} else if result.Burst > 1 {
+		stats.burstRest.add(result.ResponseTime)
+	}
+
+	if result.Backend != "" {
+		if stats.backendStats == nil {
+			stats.backendStats = make(map[string]*LatencySummary)
+		}
+		backendSummary, ok := stats.backendStats[result.Backend]
+		if !ok {
+			backendSummary = &LatencySummary{}
+			stats.backendStats[result.Backend] = backendSummary
+		}
+		backendSummary.add(result.ResponseTime)
+	}
+
+	if result.Step > 0 {
+		stats.chain.add(result)
+	}
+
+	if result.Stream > 0 {
+		if stats.streamStats == nil {
+			stats.streamStats = make(map[int]*LatencySummary)
+		}
+		streamSummary, ok := stats.streamStats[result.Stream]
+		if !ok {
+			streamSummary = &LatencySummary{MinResponseTime: result.ResponseTime}
+			stats.streamStats[result.Stream] = streamSummary
+		}
+		streamSummary.add(result.ResponseTime)
+	}
+
+	if stats.csvOut != nil {
+		if err := stats.csvOut.Write(result.csvRecord()); err != nil {
+			fmt.Printf("Error: Writing the CSV output failed: %v\n", err)
+		}
+	}
+	if stats.jsonlOut != nil {
+		stats.jsonlOut.write(result)
+	}
+}
+
+// Function to print the per-request result line.  The caller must hold the output mutex.
+func printResult(result *requestResult, cfg *testConfig) {
+	// Only the failures are printed with -dumpFailuresOnly, with their full details
+	if cfg.failuresOnly && (result.err == nil || result.Cancelled) {
+		return
+	}
+	if cfg.statusEvery > 0 && !cfg.failuresOnly {
+		return
+	}
+	if cfg.sortedOutput != nil {
+		cfg.sortedOutput.results = append(cfg.sortedOutput.results, *result)
+		return
+	}
+	name := fmt.Sprintf("Thread %2d.%-6d", result.ThreadID, result.Iteration)
+	if result.Stream > 0 {
+		name += fmt.Sprintf(" stream %-3d", result.Stream)
+	}
+	if result.CorrelationID != "" {
+		name += " - ID: " + result.CorrelationID
+	}
+	if result.Cancelled {
+		fmt.Printf("%s - Request cancelled\n", name)
+	} else if result.err != nil && cfg.failuresOnly {
+		fmt.Printf("%s - %s: %v - Status: %d - URL: %s - Retries: %d - Response time: %.2f ms\n", name,
+			colorize(cfg, "Request failed", ansiRed), result.err, result.StatusCode, result.URL, result.Retries,
+			result.ResponseTime)
+	} else if result.err != nil {
+		fmt.Printf("%s - %s: %v - Response time: %.2f ms\n", name, colorize(cfg, "Request failed", ansiRed), result.err,
+			result.ResponseTime)
+	} else {
+		fmt.Printf("%s - %s: %d %s - Response time: %.2f ms\n", name, colorize(cfg, "Success", ansiGreen), result.StatusCode,
+			http.StatusText(result.StatusCode), result.ResponseTime)
+	}
+	if cfg.verbose || cfg.failuresOnly {
+		printRedirects(result)
+	}
+}
 
-		time.Sleep(sleepTime)
+// Function to highlight the details of the request that stopped the test.  The caller must hold the output mutex.
+func printFirstFailure(result *requestResult) {
+	fmt.Println("********************************************************************************")
+	fmt.Printf("First failed request - Thread %d.%d - Stopping the test\n", result.ThreadID, result.Iteration)
+	fmt.Printf("  URL:    %s\n", result.URL)
+	if result.CorrelationID != "" {
+		fmt.Printf("  ID:     %s\n", result.CorrelationID)
 	}
+	fmt.Printf("  Status: %d %s\n", result.StatusCode, http.StatusText(result.StatusCode))
+	fmt.Printf("  Error:  %v\n", result.err)
+	fmt.Println("********************************************************************************")
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// Function to run the tester with the command line arguments.  Returns the exit code, 1 for an argument error, a
+// failed setup, or a failed test.
+func run() int {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	var responseTimes []float64
+	var stats testStats
 
 	// URL to call
 	url := ""
@@ -97,138 +1365,1829 @@ func main() {
 	sleepTime := 0 * time.Millisecond
 	// HTTP request timeout (milliseconds)
 	requestTimeOut := 10000 * time.Millisecond
+	// Most redirects a request follows
+	maxRedirects := defaultMaxRedirects
+	// Response header with the cache result, empty to not count the cache hits
+	cacheHeader := ""
 	// HTTP connection timeout (milliseconds)
 	connectTimeOut := requestTimeOut * 3
+	// Time to wait for the response headers, zero for only the request timeout
+	var responseHeaderTimeout time.Duration
+	// HTTP request method, empty for the default
+	method := ""
+	// Directory of request bodies
+	bodyDir := ""
+	// JSON Patch or Merge Patch body file and its content type
+	patchFile := ""
+	// JSON request body or "@file", empty without -jsonBody
+	jsonBody := ""
+	// Protobuf request body JSON or "@file", the descriptor set file, and the message type, empty without -protoBody
+	protoJSON := ""
+	protoDescriptor := ""
+	protoMessageName := ""
+	// Request body template repeated to the size, empty without -repeatBody
+	repeatBody := ""
+	repeatSize := 1024 * 1024
+	patchContentType := ""
+	// File of query strings
+	queryFile := ""
+	// File of weighted endpoints
+	targetFile := ""
+	// URL, method, and body of the second request of a two-step flow, and where its value comes from, empty without
+	// -chainURL
+	chainURL, chainMethod, chainBody, bodyFromResponse := "", "", "", ""
+	// OAuth2 client credentials
+	oauthTokenURL, oauthClientID, oauthClientSecret, oauthScopes := "", "", "", ""
+	// Command that prints a bearer token and how long the token is valid for
+	tokenCommand := ""
+	var tokenTTL time.Duration
+	// Command run before the requests to generate headers and a body, with its timeout and output reuse time
+	preRequestCommand := ""
+	preRequestTimeout := 5000 * time.Millisecond
+	var preRequestCache time.Duration
+	// Command run to check each response, its timeout, and the most runs at once
+	validatorCommand := ""
+	validatorTimeout := 5000 * time.Millisecond
+	validatorWorkers := runtime.NumCPU()
+	// Time the threads make unrecorded warmup calls after the ramp-up
+	var warmupDuration time.Duration
 	// Reuse the HTTP connections
 	reuseConnects := false
+	// Requests on a connection before the client closes it, zero for no limit
+	requestsPerConn := 0
+	// Write the request headers in a random order
+	randomizeHeaders := false
+	// Most seconds a connection is used before it is replaced, zero for no limit
+	var connectionLifetime time.Duration
+	// Requests in flight at once across the threads, zero for no limit
+	maxInflight := 0
+	// Average response time the concurrency is adjusted to, zero for a fixed concurrency, and the adjustment interval
+	var latencyTarget time.Duration
+	adaptInterval := 1000 * time.Millisecond
 	// Leaves all the connection requests open
 	keepConnectsOpen := false
+	// Stream the requests from stdin instead of making totalCalls to the URL
+	requestsFromStdin := false
+	// HAR capture to replay, optionally at the captured times scaled by timeScale
+	harFile := ""
+	replayTiming := false
+	// Replay the HAR requests in a loop until the total calls are sent
+	replayLoop := false
+	timeScale := 1.0
+	// Only measure the connection setup time
+	connectionsOnly := false
+	// Open the connections before the timed test
+	prewarm := false
+	// Disable TLS session resumption
+	noTLSResume := false
+	// Verify the server certificates
+	verifyTLS := false
+	// CA certificate files to verify the server certificates against, and whether the system CAs are trusted too
+	var caCerts []string
+	systemCerts := false
+	// Send one request in full detail instead of the test
+	checkOnlyMode := false
+	// Send a unique request ID in the correlation header
+	correlationID := false
+	correlationHeader := defaultCorrelationHeader
+	// Connection probe before the test
+	probeTimeout := 5000 * time.Millisecond
+	ignoreProbe := false
+	// Readiness URL polled before the test, the most time to wait, and the time between the polls
+	readyURL := ""
+	readyTimeout := 60 * time.Second
+	readyInterval := 1000 * time.Millisecond
+	// Backend addresses to rotate the connections through
+	hostsFile := ""
+	// Local port range and IP addresses the connections bind, empty for the system choice
+	localPortRange := ""
+	localAddresses := ""
+	// Color mode of the per-request results
+	colorMode := "auto"
+	// TTL of the cached host name lookups, zero to resolve the host name on every dial
+	var dnsCacheTTL time.Duration
+	// Consecutive transport errors that rebuild the HTTP client transport, zero to never rebuild
+	rebuildOnErrors := 0
+	// Sample the tester's own memory statistics
+	runtimeStats := false
+	// IP version to connect with
+	ipVersion := "auto"
+	// Apdex satisfied threshold (milliseconds), zero for no Apdex score
+	apdexTarget := 0.0
+	// Rolling p99 SLA alert threshold (milliseconds), window size, and whether to stop the test on an alert
+	slaThreshold := 0.0
+	slaWindow := 1000
+	slaAbort := false
+	// Stop the test on the first failed request
+	stopOnFirstError := false
+	// Failed requests allowed before the test stops, zero for no limit
+	maxErrors := 0
+	// Service level objectives to check
+	var sloChecks []sloCheck
+	// Summary report template file
+	reportTemplate := ""
+	// Summary format and the file to append the summary CSV row to
+	outputFormat := "text"
+	summaryCSV := ""
+	// Per-request CSV output file
+	csvOut := ""
+	jsonlOut := ""
+	// Sampled individual requests file and the number of requests sampled
+	exemplarsOut := ""
+	exemplarCount := 100
+	// File to write the folded -trace phase times to
+	traceOut := ""
+	// File to write the requests per second timeline to
+	rpsTimelineFile := ""
+	// Interim percentile snapshots file and the time between the snapshots
+	metricsFile := ""
+	interval := 1000 * time.Millisecond
+	// InfluxDB line protocol file and write URL with its token, and whether to write the -interval lines too
+	influxOut, influxURL, influxToken := "", "", ""
+	influxInterval := false
+	// Time between the summary blocks printed during the test, zero for none
+	var summaryInterval time.Duration
+	// JSON summary output file
+	jsonOut := ""
+	// Settings shared by the threads
+	cfg := &testConfig{
+		retryPolicy:     "fixed",
+		retryBackoff:    100 * time.Millisecond,
+		retryMaxBackoff: 10000 * time.Millisecond,
+		saveBodiesCount: 10,
+		burstPause:      1000 * time.Millisecond,
+	}
+	// The default success predicate is only counted.  It is a constant, so it always compiles.
+	cfg.success, _ = parsePredicate(defaultSuccess)
+
+	// Add the options from the environment ahead of the command line flags
+	args, err := argsWithEnv(os.Args)
+	if err != nil {
+		fmt.Printf("Error: %v.\n", err)
+		printHelp()
+		return 1
+	}
+	os.Args = args
 
 	// Check if there are enough arguments
 	if len(os.Args) < 2 {
 		fmt.Println("Error: No command line argument provided.")
 		printHelp()
-		return
+		return 1
 	}
 
-	// Check for help flag
+	// Check for help, the flags that make the URL optional, and the keep-alive comparison
+	urlOptional, compareMode, targetsMode, stdinMode := false, false, false, false
 	for _, arg := range os.Args[1:] {
 		if arg == "-?" || arg == "--help" {
 			printHelp()
-			return
+			return 0
 		}
+		if arg == "-requestsFromStdin" || arg == "-har" || arg == "-target" {
+			urlOptional = true
+		}
+		compareMode = compareMode || arg == "-compareKeepAlive"
+		targetsMode = targetsMode || arg == "-compareTargets"
+		stdinMode = stdinMode || arg == "-requestsFromStdin"
+	}
+
+	// Run the test of a coordinator, or coordinate the workers, instead of running the test here.  Both need the same
+	// -workerToken, so the worker only runs the tests of its coordinator.
+	workerAddress, workerMode := argValue(os.Args[1:], "-worker")
+	coordinatorAddress, coordinatorMode := argValue(os.Args[1:], "-coordinator")
+	if workerMode || coordinatorMode {
+		numWorkers := 1
+		if value, found := argValue(os.Args[1:], "-workers"); found && coordinatorMode {
+			var err error
+			if numWorkers, err = strconv.Atoi(value); err != nil || numWorkers <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", value)
+				printHelp()
+				return 1
+			}
+		}
+		workerToken, _ := argValue(os.Args[1:], "-workerToken")
+		if workerToken == "" {
+			fmt.Println("Error: -worker and -coordinator need a shared -workerToken.")
+			printHelp()
+			return 1
+		}
+		if workerMode {
+			return runWorker(workerAddress, workerToken)
+		}
+		if stdinMode {
+			fmt.Println("Error: -coordinator cannot be used with -requestsFromStdin.")
+			printHelp()
+			return 1
+		}
+		return runCoordinator(coordinatorAddress, numWorkers, workerToken, os.Args[1:])
+	}
+
+	// Run the test once with keep-alive and once without, then compare the two
+	if compareMode {
+		if stdinMode || targetsMode {
+			fmt.Println("Error: -compareKeepAlive cannot be used with -requestsFromStdin or -compareTargets.")
+			printHelp()
+			return 1
+		}
+		return compareKeepAlive(os.Args[1:])
+	}
+	// Run the test against two URLs, then compare the two
+	if targetsMode {
+		if stdinMode {
+			fmt.Println("Error: -compareTargets cannot be used with -requestsFromStdin.")
+			printHelp()
+			return 1
+		}
+		return compareTargets(os.Args[1:])
+	}
+
+	// Check if the URL has a valid prefix.  The URL is optional when streaming from stdin, replaying a HAR file, or
+	// using a target file.
+	argStart := 2
+	if strings.HasPrefix(os.Args[1], "http") {
+		url = os.Args[1]
+	} else if urlOptional && strings.HasPrefix(os.Args[1], "-") {
+		argStart = 1
+	} else {
+		fmt.Printf("Error: \"%s\" is not a valid URL\n", url)
+		printHelp()
+		return 1
 	}
 
-	// Check if the URL has a valid prefix
-	if strings.HasPrefix(os.Args[1], "http") {
-		url = os.Args[1]
-	} else {
-		fmt.Printf("Error: \"%s\" is not a valid URL\n", url)
-		printHelp()
-		return
-	}
-
 	// Iterate through command line arguments
 	var argErr error
-	for i := 2; i < len(os.Args); i++ {
+	for i := argStart; i < len(os.Args); i++ {
 		if os.Args[i] == "-totalCalls" {
-			i++
+			i = nextArg(i)
 			totalCalls, argErr = strconv.Atoi(os.Args[i])
-			if argErr != nil {
+			if argErr != nil || totalCalls < 0 {
 				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
 				printHelp()
-				return
+				return 1
 			}
 		} else if os.Args[i] == "-numThreads" {
-			i++
+			i = nextArg(i)
 			numThreads, argErr = strconv.Atoi(os.Args[i])
-			if argErr != nil {
+			if argErr != nil || numThreads < 1 {
 				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
 				printHelp()
-				return
+				return 1
 			}
 		} else if os.Args[i] == "-sleepTime" {
-			i++
+			i = nextArg(i)
 			sleepTime, argErr = time.ParseDuration(os.Args[i] + "ms")
 			if argErr != nil {
 				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
 				printHelp()
-				return
+				return 1
 			}
 		} else if os.Args[i] == "-requestTimeOut" {
-			i++
+			i = nextArg(i)
 			requestTimeOut, argErr = time.ParseDuration(os.Args[i] + "ms")
 			if argErr != nil {
 				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
 				printHelp()
-				return
+				return 1
+			}
+		} else if os.Args[i] == "-maxRedirects" {
+			i = nextArg(i)
+			maxRedirects, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || maxRedirects < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
 			}
 		} else if os.Args[i] == "-connectTimeOut" {
-			i++
+			i = nextArg(i)
 			connectTimeOut, argErr = time.ParseDuration(os.Args[i] + "ms")
 			if argErr != nil {
 				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
 				printHelp()
-				return
+				return 1
+			}
+		} else if os.Args[i] == "-responseHeaderTimeout" {
+			i = nextArg(i)
+			responseHeaderTimeout, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || responseHeaderTimeout < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-oauthTokenURL" {
+			i = nextArg(i)
+			oauthTokenURL = os.Args[i]
+		} else if os.Args[i] == "-oauthClientID" {
+			i = nextArg(i)
+			oauthClientID = os.Args[i]
+		} else if os.Args[i] == "-oauthClientSecret" {
+			i = nextArg(i)
+			oauthClientSecret = os.Args[i]
+		} else if os.Args[i] == "-oauthScopes" {
+			i = nextArg(i)
+			oauthScopes = os.Args[i]
+		} else if os.Args[i] == "-tokenCommand" {
+			i = nextArg(i)
+			tokenCommand = os.Args[i]
+		} else if os.Args[i] == "-tokenTTL" {
+			i = nextArg(i)
+			tokenTTL, argErr = time.ParseDuration(os.Args[i] + "s")
+			if argErr != nil || tokenTTL < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-preRequestCommand" {
+			i = nextArg(i)
+			preRequestCommand = os.Args[i]
+		} else if os.Args[i] == "-preRequestTimeout" {
+			i = nextArg(i)
+			preRequestTimeout, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || preRequestTimeout <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-validatorCommand" {
+			i = nextArg(i)
+			validatorCommand = os.Args[i]
+		} else if os.Args[i] == "-validatorTimeout" {
+			i = nextArg(i)
+			validatorTimeout, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || validatorTimeout <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-validatorWorkers" {
+			i = nextArg(i)
+			validatorWorkers, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || validatorWorkers < 1 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-preRequestCache" {
+			i = nextArg(i)
+			preRequestCache, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || preRequestCache < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-rampUp" {
+			i = nextArg(i)
+			cfg.rampUp, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-startJitter" {
+			i = nextArg(i)
+			cfg.startJitter, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || cfg.startJitter < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-cacheWarm" {
+			cfg.cacheWarm = true
+		} else if os.Args[i] == "-cacheHeader" {
+			i = nextArg(i)
+			cacheHeader = os.Args[i]
+		} else if os.Args[i] == "-warmup" {
+			i = nextArg(i)
+			cfg.warmupCalls, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-warmupDuration" {
+			i = nextArg(i)
+			warmupDuration, argErr = time.ParseDuration(os.Args[i] + "s")
+			if argErr != nil || warmupDuration < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-burst" {
+			i = nextArg(i)
+			cfg.burst, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || cfg.burst < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-jitterClock" {
+			cfg.jitterClock = true
+		} else if os.Args[i] == "-burstPause" {
+			i = nextArg(i)
+			cfg.burstPause, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-streams" {
+			i = nextArg(i)
+			cfg.streams, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || cfg.streams <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-correlationId" {
+			correlationID = true
+		} else if os.Args[i] == "-correlationHeader" {
+			i = nextArg(i)
+			correlationHeader = os.Args[i]
+		} else if os.Args[i] == "-verifyEcho" {
+			i = nextArg(i)
+			check, err := parseEchoCheck(os.Args[i])
+			if err != nil {
+				fmt.Printf("Error: \"%s\" is not a valid echo check: %v\n", os.Args[i], err)
+				printHelp()
+				return 1
+			}
+			cfg.echo = check
+		} else if os.Args[i] == "-checkOnly" {
+			checkOnlyMode = true
+		} else if os.Args[i] == "-dumpFailuresOnly" {
+			cfg.failuresOnly = true
+		} else if os.Args[i] == "-color" {
+			i = nextArg(i)
+			colorMode = os.Args[i]
+		} else if os.Args[i] == "-statusEveryN" {
+			i = nextArg(i)
+			cfg.statusEvery, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || cfg.statusEvery < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+			stats.statusEvery = cfg.statusEvery
+		} else if os.Args[i] == "-sortOutput" {
+			cfg.sortedOutput = &sortedOutput{}
+		} else if os.Args[i] == "-runtimeStats" {
+			runtimeStats = true
+		} else if os.Args[i] == "-probeTimeout" {
+			i = nextArg(i)
+			probeTimeout, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || probeTimeout <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-ignoreProbe" {
+			ignoreProbe = true
+		} else if os.Args[i] == "-waitForReady" {
+			i = nextArg(i)
+			readyURL = os.Args[i]
+		} else if os.Args[i] == "-readyTimeout" {
+			i = nextArg(i)
+			readyTimeout, argErr = time.ParseDuration(os.Args[i] + "s")
+			if argErr != nil || readyTimeout <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid number.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-readyInterval" {
+			i = nextArg(i)
+			readyInterval, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || readyInterval <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-hostsFile" {
+			i = nextArg(i)
+			hostsFile = os.Args[i]
+		} else if os.Args[i] == "-localPortRange" {
+			i = nextArg(i)
+			localPortRange = os.Args[i]
+		} else if os.Args[i] == "-dnsCache" {
+			i = nextArg(i)
+			dnsCacheTTL, argErr = time.ParseDuration(os.Args[i] + "s")
+			if argErr != nil || dnsCacheTTL <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-localAddresses" {
+			i = nextArg(i)
+			localAddresses = os.Args[i]
+		} else if os.Args[i] == "-rebuildOnErrors" {
+			i = nextArg(i)
+			rebuildOnErrors, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || rebuildOnErrors < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-verifyTLS" {
+			verifyTLS = true
+		} else if os.Args[i] == "-caCert" {
+			i = nextArg(i)
+			caCerts = append(caCerts, os.Args[i])
+			verifyTLS = true
+		} else if os.Args[i] == "-systemCerts" {
+			systemCerts = true
+		} else if os.Args[i] == "-noTLSResume" {
+			noTLSResume = true
+		} else if os.Args[i] == "-prewarm" {
+			prewarm = true
+		} else if os.Args[i] == "-rotateConnAfter" {
+			i = nextArg(i)
+			cfg.rotateConnAfter, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || cfg.rotateConnAfter < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-requestsPerConn" {
+			i = nextArg(i)
+			requestsPerConn, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || requestsPerConn < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-connectionLifetime" {
+			i = nextArg(i)
+			connectionLifetime, argErr = time.ParseDuration(os.Args[i] + "s")
+			if argErr != nil || connectionLifetime <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid number.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-latencyTarget" {
+			i = nextArg(i)
+			latencyTarget, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || latencyTarget <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-adaptInterval" {
+			i = nextArg(i)
+			adaptInterval, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || adaptInterval <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-maxInflight" {
+			i = nextArg(i)
+			maxInflight, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || maxInflight < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
 			}
 		} else if os.Args[i] == "-reuseConnects" {
 			reuseConnects = true
 		} else if os.Args[i] == "-keepConnectsOpen" {
 			keepConnectsOpen = true
+		} else if os.Args[i] == "-requestsFromStdin" {
+			requestsFromStdin = true
+		} else if os.Args[i] == "-har" {
+			i = nextArg(i)
+			harFile = os.Args[i]
+		} else if os.Args[i] == "-replayLoop" {
+			replayLoop = true
+		} else if os.Args[i] == "-replayTiming" {
+			replayTiming = true
+		} else if os.Args[i] == "-maxRPSPerThread" {
+			i = nextArg(i)
+			cfg.maxRPSPerThread, argErr = strconv.ParseFloat(os.Args[i], 64)
+			if argErr != nil || cfg.maxRPSPerThread < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid number.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-timeScale" {
+			i = nextArg(i)
+			timeScale, argErr = strconv.ParseFloat(os.Args[i], 64)
+			if argErr != nil || timeScale < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid number.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-verbose" {
+			cfg.verbose = true
+		} else if os.Args[i] == "-retries" {
+			i = nextArg(i)
+			cfg.retries, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || cfg.retries < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-retryPolicy" {
+			i = nextArg(i)
+			if os.Args[i] != "fixed" && os.Args[i] != "exponential" {
+				fmt.Printf("Error: \"%s\" is not a valid retry policy.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+			cfg.retryPolicy = os.Args[i]
+		} else if os.Args[i] == "-retryBackoff" {
+			i = nextArg(i)
+			cfg.retryBackoff, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || cfg.retryBackoff < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-connectionsOnly" {
+			connectionsOnly = true
+		} else if os.Args[i] == "-ipVersion" {
+			i = nextArg(i)
+			ipVersion = os.Args[i]
+			if ipNetwork(ipVersion) == "" {
+				fmt.Printf("Error: \"%s\" is not a valid IP version.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-method" {
+			i = nextArg(i)
+			method = strings.ToUpper(os.Args[i])
+		} else if os.Args[i] == "-target" {
+			i = nextArg(i)
+			targetFile = os.Args[i]
+		} else if os.Args[i] == "-chainURL" {
+			i = nextArg(i)
+			chainURL = os.Args[i]
+		} else if os.Args[i] == "-chainMethod" {
+			i = nextArg(i)
+			chainMethod = os.Args[i]
+		} else if os.Args[i] == "-chainBody" {
+			i = nextArg(i)
+			chainBody = os.Args[i]
+		} else if os.Args[i] == "-bodyFromResponse" {
+			i = nextArg(i)
+			bodyFromResponse = os.Args[i]
+		} else if os.Args[i] == "-queryFile" {
+			i = nextArg(i)
+			queryFile = os.Args[i]
+		} else if os.Args[i] == "-bodyDir" {
+			i = nextArg(i)
+			bodyDir = os.Args[i]
+		} else if os.Args[i] == "-jsonPatch" || os.Args[i] == "-mergePatch" {
+			patchContentType = jsonPatchContentType
+			if os.Args[i] == "-mergePatch" {
+				patchContentType = mergePatchContentType
+			}
+			i = nextArg(i)
+			patchFile = os.Args[i]
+		} else if os.Args[i] == "-jsonBody" {
+			i = nextArg(i)
+			jsonBody = os.Args[i]
+		} else if os.Args[i] == "-protoBody" {
+			i = nextArg(i)
+			protoJSON = os.Args[i]
+		} else if os.Args[i] == "-protoDescriptor" {
+			i = nextArg(i)
+			protoDescriptor = os.Args[i]
+		} else if os.Args[i] == "-protoMessage" {
+			i = nextArg(i)
+			protoMessageName = os.Args[i]
+		} else if os.Args[i] == "-repeatBody" {
+			i = nextArg(i)
+			repeatBody = os.Args[i]
+		} else if os.Args[i] == "-repeatSize" {
+			i = nextArg(i)
+			repeatSize, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || repeatSize <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-bodySizeMin" || os.Args[i] == "-bodySizeMax" {
+			flag := os.Args[i]
+			i = nextArg(i)
+			size, err := strconv.Atoi(os.Args[i])
+			if err != nil || size < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+			if flag == "-bodySizeMin" {
+				cfg.bodySizeMin = size
+			} else {
+				cfg.bodySizeMax = size
+			}
+		} else if os.Args[i] == "-bodyDirRandom" {
+			cfg.bodyRandom = true
+		} else if os.Args[i] == "-maxBodySize" {
+			i = nextArg(i)
+			cfg.maxBodySize, argErr = strconv.ParseInt(os.Args[i], 10, 64)
+			if argErr != nil {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-success" {
+			i = nextArg(i)
+			predicate, err := parsePredicate(os.Args[i])
+			if err != nil {
+				fmt.Printf("Error: \"%s\" is not a valid success expression: %v\n", os.Args[i], err)
+				printHelp()
+				return 1
+			}
+			cfg.success = predicate
+			cfg.successRequired = true
+		} else if os.Args[i] == "-readRate" {
+			i = nextArg(i)
+			cfg.readRate, argErr = strconv.ParseInt(os.Args[i], 10, 64)
+			if argErr != nil || cfg.readRate < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-randomizeHeaders" {
+			randomizeHeaders = true
+		} else if os.Args[i] == "-expect100" {
+			cfg.expect100 = true
+		} else if os.Args[i] == "-noBodyRead" {
+			cfg.noBodyRead = true
+		} else if os.Args[i] == "-expectHeader" {
+			i = nextArg(i)
+			check, err := parseHeaderCheck(os.Args[i])
+			if err != nil {
+				fmt.Printf("Error: \"%s\" is not a valid header check: %v\n", os.Args[i], err)
+				printHelp()
+				return 1
+			}
+			cfg.expectHeaders = append(cfg.expectHeaders, check)
+		} else if os.Args[i] == "-assertJSON" {
+			i = nextArg(i)
+			assertion, err := parseJSONAssertion(os.Args[i])
+			if err != nil {
+				fmt.Printf("Error: \"%s\" is not a valid JSON assertion: %v\n", os.Args[i], err)
+				printHelp()
+				return 1
+			}
+			cfg.jsonAssertions = append(cfg.jsonAssertions, assertion)
+		} else if os.Args[i] == "-validateJSON" {
+			cfg.validateJSON = true
+		} else if os.Args[i] == "-apdexTarget" {
+			i = nextArg(i)
+			apdexTarget, argErr = strconv.ParseFloat(os.Args[i], 64)
+			if argErr != nil || apdexTarget <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid number.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-slaAlert" {
+			i = nextArg(i)
+			slaThreshold, argErr = strconv.ParseFloat(os.Args[i], 64)
+			if argErr != nil || slaThreshold <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid number.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-slaWindow" {
+			i = nextArg(i)
+			slaWindow, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || slaWindow <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-slaAbort" {
+			slaAbort = true
+		} else if os.Args[i] == "-maxErrors" {
+			i = nextArg(i)
+			maxErrors, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || maxErrors < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-stopOnFirstError" {
+			stopOnFirstError = true
+		} else if os.Args[i] == "-slo" {
+			i = nextArg(i)
+			check, err := parseSLO(os.Args[i])
+			if err != nil {
+				fmt.Printf("Error: \"%s\" is not a valid SLO: %v\n", os.Args[i], err)
+				printHelp()
+				return 1
+			}
+			sloChecks = append(sloChecks, check)
+		} else if os.Args[i] == "-slaFile" {
+			i = nextArg(i)
+			checks, err := loadSLAFile(os.Args[i])
+			if err != nil {
+				fmt.Printf("Error: Reading the SLA file \"%s\" failed: %v\n", os.Args[i], err)
+				return 1
+			}
+			sloChecks = append(sloChecks, checks...)
+		} else if os.Args[i] == "-reportTemplate" {
+			i = nextArg(i)
+			reportTemplate = os.Args[i]
+		} else if os.Args[i] == "-output" {
+			i = nextArg(i)
+			outputFormat = os.Args[i]
+			if outputFormat != "text" && outputFormat != "csv" {
+				fmt.Printf("Error: \"%s\" is not a valid output format.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-summaryCSV" {
+			i = nextArg(i)
+			summaryCSV = os.Args[i]
+		} else if os.Args[i] == "-trace" {
+			cfg.trace = true
+		} else if os.Args[i] == "-traceOut" {
+			i = nextArg(i)
+			traceOut = os.Args[i]
+			cfg.trace = true
+		} else if os.Args[i] == "-rpsTimeline" {
+			cfg.rpsTimeline = true
+		} else if os.Args[i] == "-rpsTimelineFile" {
+			i = nextArg(i)
+			rpsTimelineFile = os.Args[i]
+		} else if os.Args[i] == "-metricsFile" {
+			i = nextArg(i)
+			metricsFile = os.Args[i]
+		} else if os.Args[i] == "-influxOut" {
+			i = nextArg(i)
+			influxOut = os.Args[i]
+		} else if os.Args[i] == "-influxUrl" {
+			i = nextArg(i)
+			influxURL = os.Args[i]
+		} else if os.Args[i] == "-influxToken" {
+			i = nextArg(i)
+			influxToken = os.Args[i]
+		} else if os.Args[i] == "-influxInterval" {
+			influxInterval = true
+		} else if os.Args[i] == "-interval" {
+			i = nextArg(i)
+			interval, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || interval <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-exemplarsOut" {
+			i = nextArg(i)
+			exemplarsOut = os.Args[i]
+		} else if os.Args[i] == "-exemplarCount" {
+			i = nextArg(i)
+			exemplarCount, argErr = strconv.Atoi(os.Args[i])
+			if argErr != nil || exemplarCount <= 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-jsonlOut" {
+			i = nextArg(i)
+			jsonlOut = os.Args[i]
+		} else if os.Args[i] == "-label" {
+			i = nextArg(i)
+			stats.label = os.Args[i]
+		} else if os.Args[i] == "-csvOut" {
+			i = nextArg(i)
+			csvOut = os.Args[i]
+		} else if os.Args[i] == "-summaryInterval" {
+			i = nextArg(i)
+			summaryInterval, argErr = time.ParseDuration(os.Args[i] + "s")
+			if argErr != nil || summaryInterval < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid number.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-jsonOut" {
+			i = nextArg(i)
+			jsonOut = os.Args[i]
+		} else if os.Args[i] == "-saveBodies" {
+			i = nextArg(i)
+			cfg.saveBodiesDir = os.Args[i]
+		} else if os.Args[i] == "-saveBodiesCount" {
+			i = nextArg(i)
+			cfg.saveBodiesCount, argErr = strconv.ParseInt(os.Args[i], 10, 64)
+			if argErr != nil {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		} else if os.Args[i] == "-retryMaxBackoff" {
+			i = nextArg(i)
+			cfg.retryMaxBackoff, argErr = time.ParseDuration(os.Args[i] + "ms")
+			if argErr != nil || cfg.retryMaxBackoff < 0 {
+				fmt.Printf("Error: \"%s\" is not a valid integer.\n", os.Args[i])
+				printHelp()
+				return 1
+			}
+		}
+	}
+
+	if cfg.noBodyRead && (cfg.validateJSON || cfg.success.needsBody || len(cfg.jsonAssertions) > 0 ||
+		cfg.saveBodiesDir != "" || cfg.maxBodySize > 0 || validatorCommand != "") {
+		fmt.Println("Error: -noBodyRead cannot be used with -validateJSON, -assertJSON, a body -success, -saveBodies, " +
+			"-maxBodySize, or -validatorCommand.")
+		printHelp()
+		return 1
+	}
+
+	// Create the directory for the saved response bodies
+	if cfg.saveBodiesDir != "" {
+		if err := os.MkdirAll(cfg.saveBodiesDir, 0755); err != nil {
+			fmt.Printf("Error: Creating the directory \"%s\" failed: %v\n", cfg.saveBodiesDir, err)
+			return 1
+		}
+	}
+
+	// Parse the report template before the test so errors are found early
+	var report *template.Template
+	if reportTemplate != "" && outputFormat == "csv" {
+		fmt.Println("Error: -reportTemplate cannot be used with -output csv.")
+		printHelp()
+		return 1
+	}
+	if reportTemplate != "" {
+		var err error
+		report, err = template.ParseFiles(reportTemplate)
+		if err != nil {
+			fmt.Printf("Error: Parsing the report template \"%s\" failed: %v\n", reportTemplate, err)
+			return 1
+		}
+	}
+
+	// Load the weighted endpoints.  Each target has its own URL and body, so the query and body flags do not apply.
+	if targetFile != "" && (queryFile != "" || bodyDir != "" || patchFile != "" || cfg.bodySizeMax > 0 ||
+		repeatBody != "" || jsonBody != "" || protoJSON != "") {
+		fmt.Println("Error: -target cannot be used with -queryFile, -bodyDir, -jsonPatch, -mergePatch, -bodySizeMax, " +
+			"-repeatBody, -jsonBody, or -protoBody.  Set the body of each target in the target file.")
+		printHelp()
+		return 1
+	}
+	if targetFile != "" {
+		var err error
+		cfg.targets, err = loadTargetFile(targetFile, url)
+		if err == nil {
+			cfg.targetPicker, err = newTargetPicker(cfg.targets)
+		}
+		if err != nil {
+			fmt.Printf("Error: Reading the target file \"%s\" failed: %v\n", targetFile, err)
+			return 1
+		}
+	}
+
+	// Load the query strings
+	if queryFile != "" {
+		var err error
+		cfg.queries, err = loadQueryFile(queryFile)
+		if err != nil {
+			fmt.Printf("Error: Reading the query file \"%s\" failed: %v\n", queryFile, err)
+			return 1
+		}
+		if len(cfg.queries) == 0 {
+			fmt.Printf("Error: The query file \"%s\" has no query strings.\n", queryFile)
+			return 1
+		}
+	}
+
+	// Load the request bodies
+	if bodyDir != "" {
+		var err error
+		cfg.bodyFiles, err = loadBodyDir(bodyDir)
+		if err != nil {
+			fmt.Printf("Error: Reading the body directory \"%s\" failed: %v\n", bodyDir, err)
+			return 1
+		}
+		if len(cfg.bodyFiles) == 0 {
+			fmt.Printf("Error: The body directory \"%s\" has no files.\n", bodyDir)
+			return 1
+		}
+		if method == "" {
+			method = "POST"
+		}
+	}
+	if patchFile != "" {
+		if bodyDir != "" {
+			fmt.Println("Error: -jsonPatch and -mergePatch cannot be used with -bodyDir.")
+			printHelp()
+			return 1
+		}
+		file, err := loadPatchFile(patchFile)
+		if err != nil {
+			fmt.Printf("Error: Reading the patch file \"%s\" failed: %v\n", patchFile, err)
+			return 1
+		}
+		cfg.bodyFiles = []bodyFile{file}
+		cfg.contentType = patchContentType
+		if method == "" {
+			method = "PATCH"
+		}
+	}
+	if cfg.bodySizeMax > 0 {
+		if bodyDir != "" || patchFile != "" || cfg.bodySizeMin > cfg.bodySizeMax {
+			fmt.Println("Error: -bodySizeMax must be at least -bodySizeMin and cannot be used with -bodyDir or a patch.")
+			printHelp()
+			return 1
+		}
+		cfg.bodyFiller = bytes.Repeat([]byte{'x'}, cfg.bodySizeMax)
+		if method == "" {
+			method = "POST"
+		}
+	}
+	if repeatBody != "" {
+		if bodyDir != "" || patchFile != "" || cfg.bodySizeMax > 0 {
+			fmt.Println("Error: -repeatBody cannot be used with -bodyDir, a patch, or -bodySizeMax.")
+			printHelp()
+			return 1
+		}
+		file, err := repeatedBody(repeatBody, repeatSize)
+		if err != nil {
+			fmt.Printf("Error: Reading the repeated body \"%s\" failed: %v\n", repeatBody, err)
+			return 1
+		}
+		cfg.bodyFiles = []bodyFile{file}
+		fmt.Printf("Repeated request body size: %d B\n", len(file.data))
+		if method == "" {
+			method = "POST"
+		}
+	}
+	if jsonBody != "" {
+		if bodyDir != "" || patchFile != "" || cfg.bodySizeMax > 0 || repeatBody != "" {
+			fmt.Println("Error: -jsonBody cannot be used with -bodyDir, a patch, -bodySizeMax, or -repeatBody.")
+			printHelp()
+			return 1
+		}
+		file, err := loadJSONBody(jsonBody)
+		if err != nil {
+			fmt.Printf("Error: Reading the JSON body \"%s\" failed: %v\n", jsonBody, err)
+			return 1
+		}
+		cfg.bodyFiles = []bodyFile{file}
+		cfg.contentType = "application/json"
+		if method == "" {
+			method = "POST"
+		}
+	}
+	if protoJSON != "" {
+		if bodyDir != "" || patchFile != "" || cfg.bodySizeMax > 0 || repeatBody != "" || jsonBody != "" {
+			fmt.Println("Error: -protoBody cannot be used with another request body option.")
+			printHelp()
+			return 1
+		}
+		if protoDescriptor == "" || protoMessageName == "" {
+			fmt.Println("Error: -protoBody requires -protoDescriptor and -protoMessage.")
+			printHelp()
+			return 1
+		}
+		schema, err := loadProtoDescriptor(protoDescriptor)
+		if err != nil {
+			fmt.Printf("Error: Reading the protobuf descriptor \"%s\" failed: %v\n", protoDescriptor, err)
+			return 1
+		}
+		body, data, err := newProtoBody(schema, protoMessageName, protoJSON)
+		if err != nil {
+			fmt.Printf("Error: Encoding the protobuf body failed: %v\n", err)
+			return 1
+		}
+		if body.templated {
+			cfg.protoBody = body
+		} else {
+			cfg.bodyFiles = []bodyFile{{data: data}}
+		}
+		cfg.contentType = protobufContentType
+		if method == "" {
+			method = "POST"
+		}
+	}
+	if method == "" {
+		method = "GET"
+	}
+
+	if exemplarsOut != "" {
+		stats.exemplars = &exemplarReservoir{size: exemplarCount, label: stats.label}
+	}
+
+	if prewarm && (!reuseConnects || url == "" || connectionsOnly) {
+		fmt.Println("Error: -prewarm requires -reuseConnects and a URL, and cannot be used with -connectionsOnly.")
+		printHelp()
+		return 1
+	}
+
+	var colorErr error
+	if cfg.color, colorErr = useColor(colorMode); colorErr != nil {
+		fmt.Printf("Error: %v.\n", colorErr)
+		printHelp()
+		return 1
+	}
+	if systemCerts && len(caCerts) == 0 {
+		fmt.Println("Error: -systemCerts requires -caCert.")
+		printHelp()
+		return 1
+	}
+	if requestsPerConn > 0 && !reuseConnects {
+		fmt.Println("Error: -requestsPerConn requires -reuseConnects.")
+		printHelp()
+		return 1
+	}
+	if requestsPerConn > 0 {
+		stats.connLimit = newConnRequestLimit(requestsPerConn)
+	}
+	// The body of an Expect request is written after a response, which would look like the next request head
+	if randomizeHeaders && cfg.expect100 {
+		fmt.Println("Error: -randomizeHeaders cannot be used with -expect100.")
+		printHelp()
+		return 1
+	}
+	// The header lines of an https request are encrypted on their way to the connection, so they cannot be shuffled
+	if randomizeHeaders {
+		httpsURLs := []string{url, chainURL}
+		for _, target := range cfg.targets {
+			httpsURLs = append(httpsURLs, target.URL)
+		}
+		if slices.ContainsFunc(httpsURLs, isHTTPS) {
+			fmt.Println("Error: -randomizeHeaders cannot be used with an https URL.")
+			printHelp()
+			return 1
+		}
+	}
+	if connectionLifetime > 0 && !reuseConnects {
+		fmt.Println("Error: -connectionLifetime requires -reuseConnects.")
+		printHelp()
+		return 1
+	}
+	if connectionLifetime > 0 && requestsPerConn > 0 {
+		fmt.Println("Error: -connectionLifetime cannot be used with -requestsPerConn.")
+		printHelp()
+		return 1
+	}
+	if connectionLifetime > 0 {
+		stats.connAge = newConnLifetime(connectionLifetime)
+	}
+	if cfg.cacheWarm && cacheHeader == "" {
+		cacheHeader = defaultCacheHeader
+	}
+	if cacheHeader != "" {
+		stats.cache = &cacheStats{header: cacheHeader}
+	}
+	if chainURL == "" && (chainMethod != "" || chainBody != "" || bodyFromResponse != "") {
+		fmt.Println("Error: -chainMethod, -chainBody, and -bodyFromResponse require -chainURL.")
+		printHelp()
+		return 1
+	}
+	if chainURL != "" && (requestsFromStdin || harFile != "" || connectionsOnly) {
+		fmt.Println("Error: -chainURL cannot be used with -requestsFromStdin, -har, or -connectionsOnly.")
+		printHelp()
+		return 1
+	}
+	if chainURL != "" {
+		if bodyFromResponse == "" {
+			bodyFromResponse = defaultChainSource
+		}
+		if chainMethod == "" {
+			chainMethod = "GET"
+		}
+		var err error
+		cfg.chain, err = newResponseChain(bodyFromResponse, chainMethod, chainURL, chainBody)
+		if err != nil {
+			fmt.Printf("Error: \"%s\" is not a valid -bodyFromResponse source: %v\n", bodyFromResponse, err)
+			printHelp()
+			return 1
+		}
+		firstStep := method + " " + url
+		if targetFile != "" {
+			firstStep = "targets from " + targetFile
 		}
+		stats.chain = &chainStats{names: [2]string{firstStep, cfg.chain.method + " " + chainURL}}
 	}
+	if cfg.maxRPSPerThread > 0 {
+		stats.threadRates = &threadRates{limit: cfg.maxRPSPerThread}
+	}
+	if cfg.jitterClock && sleepTime == 0 && cfg.burst == 0 && cfg.maxRPSPerThread == 0 {
+		fmt.Println("Error: -jitterClock requires -sleepTime, -burst, or -maxRPSPerThread.")
+		printHelp()
+		return 1
+	}
+	if cfg.jitterClock {
+		stats.schedulingDelays = &schedulingDelays{}
+	}
+	if influxOut == "" && influxURL == "" && (influxToken != "" || influxInterval) {
+		fmt.Println("Error: -influxToken and -influxInterval require -influxOut or -influxUrl.")
+		printHelp()
+		return 1
+	}
+	var influx *influxWriter
+	if influxOut != "" || influxURL != "" {
+		influx = newInfluxWriter(influxOut, influxURL, influxToken, stats.label, url, requestTimeOut)
+	}
+	if maxInflight > 0 && latencyTarget > 0 {
+		fmt.Println("Error: -maxInflight cannot be used with -latencyTarget, which sets the requests in flight itself.")
+		printHelp()
+		return 1
+	}
+	if maxInflight > 0 {
+		stats.inflightSlots = make(chan struct{}, maxInflight)
+	}
+	if latencyTarget > 0 {
+		stats.adaptive = newAdaptiveLimit(numThreads)
+	}
+	stats.slaScopes = slaScopes(sloChecks)
+
+	// Threads without a call to make would only sit idle, except when the requests come from stdin or a HAR file
+	if totalCalls == 0 && !requestsFromStdin && harFile == "" {
+		fmt.Println("Error: -totalCalls must be at least 1.")
+		printHelp()
+		return 1
+	}
+	if totalCalls > 0 && numThreads > totalCalls && !requestsFromStdin && harFile == "" {
+		fmt.Printf("Warning: -numThreads %d is more than -totalCalls %d, using %d threads.\n", numThreads, totalCalls,
+			totalCalls)
+		numThreads = totalCalls
+	}
+
+	cfg.numThreads = numThreads
+	cfg.requestTimeout = requestTimeOut
+	cfg.responseHeaderTimeout = responseHeaderTimeout
+	cfg.sleepTime = sleepTime
+	// The echo check sends its own request ID unless the correlation IDs are sent already
+	if cfg.echo != nil && !correlationID {
+		correlationID = true
+		correlationHeader = cfg.echo.requestHeader
+	}
+	if correlationID {
+		cfg.correlationHeader = correlationHeader
+	}
+	cfg.keepConnectsOpen = keepConnectsOpen
+	cfg.reuseConnects = reuseConnects
 
 	// Create an HTTP client
+	dialer := &connDialer{
+		dialer:           net.Dialer{Timeout: requestTimeOut, KeepAlive: 30 * time.Second},
+		network:          ipNetwork(ipVersion),
+		randomizeHeaders: randomizeHeaders,
+	}
+	if hostsFile != "" {
+		var err error
+		dialer.hosts, err = loadHostsFile(hostsFile)
+		if err != nil {
+			fmt.Printf("Error: Reading the hosts file \"%s\" failed: %v\n", hostsFile, err)
+			return 1
+		}
+		cfg.perBackend = true
+	}
+	if localPortRange != "" {
+		var err error
+		dialer.firstPort, dialer.lastPort, err = parsePortRange(localPortRange)
+		if err != nil {
+			fmt.Printf("Error: \"%s\" is not a valid port range: %v.\n", localPortRange, err)
+			printHelp()
+			return 1
+		}
+	}
+	if dnsCacheTTL > 0 {
+		dialer.dnsCache = newDNSCache(dnsCacheTTL)
+	}
+	if localAddresses != "" {
+		var err error
+		dialer.localIPs, err = parseLocalAddresses(localAddresses)
+		if err != nil {
+			fmt.Printf("Error: %v.\n", err)
+			printHelp()
+			return 1
+		}
+	}
 	tr := &http.Transport{
-		MaxIdleConns:       numThreads * 10,
-		IdleConnTimeout:    connectTimeOut,
-		DisableCompression: true,
-		DisableKeepAlives:  !reuseConnects,
+		DialContext:     dialer.DialContext,
+		MaxIdleConns:    numThreads * 10,
+		IdleConnTimeout: connectTimeOut,
+		// The transport fails a request that gets no response headers in time
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		DisableCompression:    true,
+		DisableKeepAlives:     !reuseConnects,
+		ExpectContinueTimeout: expectContinueTimeout,
+		// The custom dialer turns off HTTP/2 unless it is forced
+		ForceAttemptHTTP2: cfg.streams > 1,
+	}
+	if prewarm {
+		// Keep every prewarmed connection idle instead of the default two per host
+		tr.MaxIdleConnsPerHost = numThreads
+	}
+	if strings.HasPrefix(strings.ToLower(url), "https") || requestsFromStdin || harFile != "" || targetFile != "" {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: !verifyTLS}
+		if len(caCerts) > 0 {
+			var err error
+			tr.TLSClientConfig.RootCAs, err = loadCertPool(caCerts, systemCerts)
+			if err != nil {
+				fmt.Printf("Error: Loading the CA certificates failed: %v\n", err)
+				return 1
+			}
+		}
+		// Resume TLS sessions on new connections like a browser unless the full handshake cost is measured
+		if noTLSResume {
+			tr.TLSClientConfig.SessionTicketsDisabled = true
+		} else {
+			tr.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+		}
 	}
-	if strings.HasPrefix(strings.ToLower(url), "https") {
-		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	client := &http.Client{Transport: tr, Timeout: requestTimeOut, CheckRedirect: redirectPolicy(maxRedirects)}
+	// A target timeout may be longer than the global request timeout
+	for _, target := range cfg.targets {
+		client.Timeout = max(client.Timeout, target.Timeout)
+	}
+	var rebuilder *rebuildingTransport
+	if rebuildOnErrors > 0 {
+		rebuilder = newRebuildingTransport(tr, rebuildOnErrors)
+		client.Transport = rebuilder
+	}
+
+	// Fetch the OAuth2 token before the test so a bad configuration fails fast
+	if oauthTokenURL != "" {
+		tokenClient := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+			Timeout:   requestTimeOut,
+		}
+		cfg.token = &bearerToken{
+			fetch: oauthClientCredentials(tokenClient, oauthTokenURL, oauthClientID, oauthClientSecret, oauthScopes),
+		}
+		if _, err := cfg.token.get(); err != nil {
+			fmt.Printf("Error: Fetching the OAuth2 token from \"%s\" failed: %v\n", oauthTokenURL, err)
+			return 1
+		}
+	} else if tokenCommand != "" {
+		fetch, err := commandToken(tokenCommand, tokenTTL)
+		if err != nil {
+			fmt.Printf("Error: \"%s\" is not a valid token command: %v\n", tokenCommand, err)
+			return 1
+		}
+		cfg.token = &bearerToken{fetch: fetch}
+		if _, err := cfg.token.get(); err != nil {
+			fmt.Printf("Error: Running the token command \"%s\" failed: %v\n", tokenCommand, err)
+			return 1
+		}
+	}
+	if preRequestCommand != "" {
+		var err error
+		cfg.preRequest, err = newPreRequestHook(preRequestCommand, preRequestTimeout, preRequestCache)
+		if err != nil {
+			fmt.Printf("Error: \"%s\" is not a valid pre-request command: %v\n", preRequestCommand, err)
+			return 1
+		}
+		if preRequestCache == 0 {
+			fmt.Println("Warning: -preRequestCommand starts a process for every request, which limits the request rate " +
+				"and loads this machine.  Use -preRequestCache to reuse the output.")
+		}
+	}
+	if validatorCommand != "" {
+		var err error
+		cfg.validator, err = newResponseValidator(validatorCommand, validatorTimeout, validatorWorkers)
+		if err != nil {
+			fmt.Printf("Error: \"%s\" is not a valid validator command: %v\n", validatorCommand, err)
+			return 1
+		}
+		fmt.Printf("Warning: -validatorCommand starts a process for every response, %d at a time, which limits the "+
+			"request rate and loads this machine.\n", validatorWorkers)
+	}
+
+	// Cancel the in-flight requests on Ctrl-C and print the summary of what ran.  A second Ctrl-C exits immediately.
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-signalCtx.Done()
+		stop()
+	}()
+	// Features that stop the test early cancel the context with the reason
+	ctx, abort := context.WithCancelCause(signalCtx)
+	defer abort(nil)
+	cfg.abort = abort
+
+	if slaThreshold > 0 {
+		var slaStop func(error)
+		if slaAbort {
+			slaStop = abort
+		}
+		stats.sla = newSLAAlert(slaThreshold, slaWindow, slaStop)
+	}
+	if stopOnFirstError {
+		stats.stopOnFailure = abort
+	}
+	if maxErrors > 0 {
+		stats.maxErrors = maxErrors
+		stats.stopOnErrors = abort
+	}
+
+	// Wait for a service that is still starting
+	if readyURL != "" {
+		fmt.Printf("Waiting for \"%s\" to be ready...\n", readyURL)
+		waitTime, err := waitForReady(ctx, readyURL, tr.TLSClientConfig, requestTimeOut, readyTimeout, readyInterval)
+		if err != nil {
+			fmt.Printf("Error: \"%s\" was not ready after %.2f s: %v\n", readyURL, waitTime.Seconds(), err)
+			return 1
+		}
+		fmt.Printf("Ready after %.2f s.\n", waitTime.Seconds())
+	}
+
+	// Fail fast if the host is unreachable
+	if url != "" && !ignoreProbe && !checkOnlyMode {
+		if err := probeConnect(ctx, dialer, url, probeTimeout); err != nil {
+			fmt.Printf("Error: Cannot connect to \"%s\": %v\n", url, err)
+			fmt.Println("Use -ignoreProbe to run the test anyway.")
+			return 1
+		}
+		// The probe connection is not part of the test
+		dialer.ipv4Conns.Store(0)
+		dialer.ipv6Conns.Store(0)
+		dialer.dnsLookups.Store(0)
+		if dialer.dnsCache != nil {
+			dialer.dnsCache.hits.Store(0)
+			dialer.dnsCache.dials.Store(0)
+		}
+	}
+
+	// A single request in full detail instead of the test
+	if checkOnlyMode {
+		passed := checkOnly(ctx, client, &stats, url, method, cfg)
+		client.CloseIdleConnections()
+		if !passed {
+			return 1
+		}
+		return 0
+	}
+
+	// Open the connections outside the timed test
+	var prewarmTime time.Duration
+	var prewarmed int
+	if prewarm {
+		prewarmTime, prewarmed = prewarmConnections(ctx, client, url, numThreads)
 	}
-	client := &http.Client{Transport: tr, Timeout: requestTimeOut}
 
 	// Calculate the number of calls each goroutine should make
-	callsPerGoroutine := totalCalls / numThreads
-	remainderCalls := totalCalls % numThreads
+	threadCalls := callsPerThread(totalCalls, numThreads)
+	if cfg.verbose && !requestsFromStdin && harFile == "" {
+		fmt.Printf("Calls per thread: %s\n", describeCallSplit(threadCalls))
+	}
+	var harRequests []harRequest
+	var schedule replaySchedule
+	var replayDone chan struct{}
+	if harFile != "" {
+		var err error
+		harRequests, err = loadHARFile(harFile)
+		if err != nil {
+			fmt.Printf("Error: Reading the HAR file \"%s\" failed: %v\n", harFile, err)
+			return 1
+		}
+		for _, harRequest := range harRequests {
+			if randomizeHeaders && isHTTPS(harRequest.request.URL) {
+				fmt.Printf("Error: -randomizeHeaders cannot be used with the https URL \"%s\" of the HAR file.\n",
+					harRequest.request.URL)
+				return 1
+			}
+		}
+	}
+	var connectAddress string
+	if connectionsOnly {
+		var err error
+		if connectAddress, err = dialAddress(url); err != nil {
+			fmt.Printf("Error: \"%s\" is not a valid URL: %v\n", url, err)
+			return 1
+		}
+	}
+
+	// Open the per-request outputs last, so a setup error, a failed probe, or a service that is not ready does not
+	// leave an empty or truncated file
+	var csvFile io.WriteCloser
+	if csvOut != "" {
+		var err error
+		csvFile, err = createOutput(csvOut)
+		if err != nil {
+			fmt.Printf("Error: Creating the CSV output \"%s\" failed: %v\n", csvOut, err)
+			return 1
+		}
+		stats.csvOut = csv.NewWriter(csvFile)
+		_ = stats.csvOut.Write(csvHeader)
+	}
+	var jsonlFile io.WriteCloser
+	if jsonlOut != "" {
+		var err error
+		jsonlFile, err = createOutput(jsonlOut)
+		if err != nil {
+			fmt.Printf("Error: Creating the JSON Lines output \"%s\" failed: %v\n", jsonlOut, err)
+			if csvFile != nil {
+				_ = csvFile.Close()
+			}
+			return 1
+		}
+		stats.jsonlOut = newJSONLWriter(jsonlFile)
+	}
+
+	var sampler *memStatsSampler
+	if runtimeStats {
+		sampler = startMemStats()
+	}
+	var metricsDone, metricsFinished chan struct{}
+	var intervalInflux *influxWriter
+	if influxInterval {
+		intervalInflux = influx
+	}
+	if metricsFile != "" || intervalInflux != nil {
+		metricsDone, metricsFinished = make(chan struct{}), make(chan struct{})
+		go writeIntervalMetrics(&mu, &stats, metricsFile, intervalInflux, interval, metricsDone, metricsFinished)
+	}
+	var snapshotDone, snapshotFinished chan struct{}
+	if summaryInterval > 0 {
+		snapshotDone, snapshotFinished = make(chan struct{}), make(chan struct{})
+		go printSummarySnapshots(&mu, &stats, url, numThreads, summaryInterval, snapshotDone, snapshotFinished)
+	}
+	var adaptiveDone, adaptiveFinished chan struct{}
+	if stats.adaptive != nil {
+		adaptiveDone, adaptiveFinished = make(chan struct{}), make(chan struct{})
+		go controlConcurrency(&mu, &stats, stats.adaptive, milliseconds(latencyTarget), adaptInterval, adaptiveDone,
+			adaptiveFinished)
+	}
 	startTime := time.Now()
-	// Create and start goroutines
-	for i := 0; i < numThreads; i++ {
-		numCalls := callsPerGoroutine
-		// Add one call to each thread number that is less than the mod of the total calls to compensate for the remainder
-		if i < remainderCalls {
-			numCalls++
+	if cfg.rpsTimeline || rpsTimelineFile != "" {
+		stats.timeline = &throughputTimeline{startTime: startTime}
+	}
+	if warmupDuration > 0 {
+		// Every thread warms up for at least the warmup time, so it ends that long after the last thread starts
+		cfg.warmupEnd = startTime.Add(cfg.rampUp + cfg.startJitter + warmupDuration)
+	}
+	if connectionsOnly {
+		address := connectAddress
+		// A verified handshake needs the server name that the transport takes from the request
+		tlsConfig := tr.TLSClientConfig
+		if tlsConfig != nil && verifyTLS {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(address)
+		}
+		for i := 0; i < numThreads; i++ {
+			wg.Add(1)
+			go connectData(ctx, &wg, &mu, &stats, dialer, address, tlsConfig, cfg, i, threadCalls[i])
+		}
+	} else if requestsFromStdin {
+		// Bounded channel so stdin is only consumed as fast as the threads can issue the requests
+		requests := make(chan stdinRequest, numThreads*2)
+		for i := 0; i < numThreads; i++ {
+			wg.Add(1)
+			go streamData(ctx, &wg, &mu, client, &stats, requests, cfg, i)
+		}
+		// Stdin reads block so the reader is not waited for if the test is interrupted
+		go readStdinRequests(ctx, os.Stdin, url, randomizeHeaders, requests)
+	} else if harFile != "" {
+		// Unbuffered so a request is only dispatched when a thread is free to send it
+		requests := make(chan stdinRequest)
+		for i := 0; i < numThreads; i++ {
+			wg.Add(1)
+			go streamData(ctx, &wg, &mu, client, &stats, requests, cfg, i)
+		}
+		loopLimit := 0
+		if replayLoop {
+			loopLimit = totalCalls
+			stats.urlStats = make(map[string]*LatencySummary)
+			stats.urlFailures = make(map[string]int)
+		}
+		replayDone = make(chan struct{})
+		go replayHARRequests(ctx, harRequests, replayTiming, timeScale, loopLimit, requests, &schedule, replayDone)
+	} else {
+		// Only the threads with calls to make take part in the steady-state window
+		stats.steady.threads = min(numThreads, totalCalls)
+		// Create and start goroutines
+		for i := 0; i < numThreads; i++ {
+			wg.Add(1)
+			go fetchData(ctx, &wg, &mu, client, &stats, url, method, cfg, i, threadCalls[i])
 		}
-		wg.Add(1)
-		go fetchData(&wg, &mu, client, &responseTimes, url, sleepTime, keepConnectsOpen, reuseConnects, i, numCalls)
 	}
 
 	// Wait for all goroutines to complete
 	wg.Wait()
 	endTime := time.Now()
+	if replayDone != nil {
+		<-replayDone
+	}
+	if metricsDone != nil {
+		close(metricsDone)
+		<-metricsFinished
+	}
+	if adaptiveDone != nil {
+		close(adaptiveDone)
+		<-adaptiveFinished
+	}
+	if snapshotDone != nil {
+		close(snapshotDone)
+		<-snapshotFinished
+	}
+	if cfg.sortedOutput != nil {
+		cfg.sortedOutput.flush(cfg)
+	}
 
-	// Calculate the total time for the test.  Use Seconds to get float value.
+	// Close the per-request CSV output
+	if csvFile != nil {
+		stats.csvOut.Flush()
+		err := stats.csvOut.Error()
+		if closeErr := csvFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fmt.Printf("Error: Writing the CSV output \"%s\" failed: %v\n", csvOut, err)
+		}
+	}
+	// Close the per-request JSON Lines output
+	if jsonlFile != nil {
+		err := stats.jsonlOut.close()
+		if closeErr := jsonlFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fmt.Printf("Error: Writing the JSON Lines output \"%s\" failed: %v\n", jsonlOut, err)
+		}
+	}
+
+	// Write the folded phase times
+	if traceOut != "" {
+		if err := writeFoldedPhases(traceOut, stats.phaseTotals); err != nil {
+			fmt.Printf("Error: Writing the trace output \"%s\" failed: %v\n", traceOut, err)
+		}
+	}
+
+	// Write the requests per second timeline
+	if rpsTimelineFile != "" {
+		if err := writeThroughputTimeline(rpsTimelineFile, stats.timeline.counts); err != nil {
+			fmt.Printf("Error: Writing the timeline file \"%s\" failed: %v\n", rpsTimelineFile, err)
+		}
+	}
+
+	// Write the sampled requests
+	if stats.exemplars != nil {
+		if err := stats.exemplars.write(exemplarsOut); err != nil {
+			fmt.Printf("Error: Writing the exemplars output \"%s\" failed: %v\n", exemplarsOut, err)
+		}
+	}
+
+	// Calculate the total time for the test.  Use Seconds to get float value.  The warmup time is not part of it.
+	if cfg.warmupEnd.After(startTime) && cfg.warmupEnd.Before(endTime) {
+		startTime = cfg.warmupEnd
+	}
 	totalTime := endTime.Sub(startTime).Seconds()
+	result := stats.summarize(url, numThreads, totalTime)
+	result.ConnectionsOpened = dialer.ipv4Conns.Load() + dialer.ipv6Conns.Load()
+	result.DNSLookups = dialer.dnsLookups.Load()
+	if dialer.dnsCache != nil {
+		result.DNSCached = true
+		result.DNSCacheHits = dialer.dnsCache.hits.Load()
+		if dials := dialer.dnsCache.dials.Load(); dials > 0 {
+			result.DNSCacheHitRate = float64(result.DNSCacheHits) / float64(dials) * 100
+		}
+	}
+	if sampler != nil {
+		sampler.finish(&result)
+	}
+	if replayLoop {
+		result.ReplayLoops = schedule.loops
+	}
+	if stats.adaptive != nil {
+		result.LatencyTarget = milliseconds(latencyTarget)
+		result.ConcurrencyTimeline = stats.adaptiveSteps
+		result.SustainedRequestsPerSecond, result.KneeConcurrency = sustainedThroughput(stats.adaptiveSteps,
+			result.LatencyTarget)
+	}
+	if delays := stats.schedulingDelays; delays != nil {
+		result.ScheduledWaits, result.MaxSchedulingDelay = delays.count, delays.max
+		result.AverageSchedulingDelay, result.FirstHalfSchedulingDelay, result.SecondHalfSchedulingDelay =
+			delays.averages()
+	}
+	if stats.threadRates != nil {
+		result.MaxRPSPerThread = stats.threadRates.limit
+		result.AverageThreadRPS, result.MinThreadRPS, result.MaxThreadRPS = stats.threadRates.summarize()
+	}
+	if schedule.dispatched > 0 {
+		result.ReplayedRequests = schedule.dispatched
+		result.AverageScheduleLateness = milliseconds(schedule.totalLate) / float64(schedule.dispatched)
+		result.MaxScheduleLateness = milliseconds(schedule.maxLate)
+	}
+	if prewarm {
+		result.PrewarmTime = milliseconds(prewarmTime)
+		result.PrewarmedConnections = prewarmed
+	}
+	if apdexTarget > 0 {
+		result.ApdexTarget = apdexTarget
+		result.Apdex = apdexScore(stats.responseTimes, apdexTarget)
+	}
+
+	result.IPv4Connections = dialer.ipv4Conns.Load()
+	result.IPv6Connections = dialer.ipv6Conns.Load()
+	if !connectionsOnly {
+		result.SuccessPredicate = cfg.success.text
+		result.SatisfiedRequests = stats.satisfied
+	}
+	result.ConnectionRotations = stats.rotations
+	result.LocalPortFailures = stats.localPortFailures
+	result.ConnectionFailures = stats.connectFailures
+	result.FailedAfterConnecting = stats.failures - stats.connectFailures
+	result.HeaderCheckFailures = stats.headerFailures
+	result.JSONAssertionFailures = stats.jsonAssertFailures
+	result.ValidatorFailures = stats.validatorFailures
+	result.EchoMissing, result.EchoMismatches = stats.echoMissing, stats.echoMismatches
+	if cfg.validator != nil {
+		result.ValidatorRuns, result.AverageValidatorTime = cfg.validator.summary()
+	}
+	if cfg.readRate > 0 {
+		result.TotalHoldTime = stats.holdTimes.totalResponseTime / 1000
+		result.AverageHoldTime = stats.holdTimes.AverageResponseTime
+		result.MaxHoldTime = stats.holdTimes.MaxResponseTime
+	}
+	if responseHeaderTimeout > 0 {
+		result.ResponseHeaderTimeouts = stats.headerTimeouts
+	}
+	if stats.timeline != nil {
+		result.RequestsPerSecondTimeline = stats.timeline.counts
+	}
+	if limit := stats.connLimit; limit != nil {
+		result.RequestsPerConnection = limit.limit
+		result.ConnectionsLimited = limit.limited
+		result.ServerClosedAtLimit = limit.serverClosedAtLimit
+		result.FailedAtLimit = limit.failedAtLimit
+		result.ServerClosedBeforeLimit = limit.serverClosedEarly
+		result.MaxRequestsBeforeServerClose = limit.maxEarlyRequests
+	}
+	if cache := stats.cache; cache != nil {
+		result.CacheHeader = cache.header
+		result.CacheHits, result.CacheMisses, result.WithoutCacheHeader = cache.hits, cache.misses, cache.missing
+		result.CacheHitRate = cache.hitRate()
+	}
+	if stats.chain != nil {
+		result.ChainSteps, result.ChainSkipped = stats.chain.summary(), stats.chain.skipped
+	}
+	result.ExpectContinueRequests, result.Got100Continue = stats.expectContinue, stats.got100
+	if stats.got100 > 0 {
+		result.AverageContinueWait = stats.continueWaitTotal / float64(stats.got100)
+	}
+	if age := stats.connAge; age != nil {
+		result.ConnectionLifetime = age.lifetime.Seconds()
+		result.ConnectionsRecycled = age.recycled
+	}
+	if rebuilder != nil {
+		result.ClientRebuilds = rebuilder.rebuilds.Load()
+	}
+	result.TLSHandshakes = stats.handshakes.count.Load()
+	result.TLSResumedHandshakes = stats.handshakes.resumed.Load()
+	result.AverageTLSHandshakeTime = stats.handshakes.average()
+	result.Interrupted = signalCtx.Err() != nil
+	if cause := context.Cause(ctx); cause != nil && !result.Interrupted {
+		result.AbortReason = cause.Error()
+	}
+	if stats.sla != nil {
+		result.SLAAlerts = stats.sla.alerts
+	}
+	if cfg.token != nil {
+		result.TokenRefreshes = cfg.token.refreshCount()
+		result.AuthRetries = stats.authRetries
+	}
+	if cfg.preRequest != nil {
+		result.PreRequestRuns, result.AveragePreRequestTime, result.PreRequestCacheHits = cfg.preRequest.summary()
+	}
 
-	// Calculate the average requests per second
-	requestsPerSecond := float64(totalCalls) / totalTime
+	// Check the service level objectives
+	result.SLOs = evaluateSLOs(sloChecks, stats.sortedTimes, &result)
 
-	// Calculate and print the average response time
-	var totalResponseTime float64
-	for _, rt := range responseTimes {
-		totalResponseTime += rt
+	// Print the summary using the report template, the CSV row, or the built-in format
+	if report != nil {
+		if err := report.Execute(os.Stdout, &result); err != nil {
+			fmt.Printf("Error: Executing the report template \"%s\" failed: %v\n", reportTemplate, err)
+		}
+	} else if outputFormat == "csv" {
+		_ = writeSummaryCSV(os.Stdout, &result, endTime, true)
+	} else {
+		printSummary(&result, cfg, &stats, connectionsOnly)
 	}
-	averageResponseTime := totalResponseTime / float64(len(responseTimes))
 
-	fmt.Printf("Total thread count: %d\n", numThreads)
-	fmt.Printf("Total test time: %.2f s\n", totalTime)
-	fmt.Printf("Average response time: %.2f ms\n", averageResponseTime)
-	fmt.Printf("Average requests per second: %.2f\n", requestsPerSecond)
+	// Write the JSON summary
+	if jsonOut != "" {
+		if err := writeJSON(jsonOut, &result); err != nil {
+			fmt.Printf("Error: Writing the JSON summary \"%s\" failed: %v\n", jsonOut, err)
+		}
+	}
+
+	// Append the summary CSV row
+	if summaryCSV != "" {
+		if err := appendSummaryCSV(summaryCSV, &result, endTime); err != nil {
+			fmt.Printf("Error: Writing the summary CSV \"%s\" failed: %v\n", summaryCSV, err)
+		}
+	}
+
+	// Write the InfluxDB summary line
+	if influx != nil {
+		if err := influx.write(influx.summaryLine(&result, endTime)); err != nil {
+			fmt.Printf("Error: Writing the InfluxDB summary failed: %v\n", err)
+		}
+	}
 
 	// Dump all the connection states
 	client.CloseIdleConnections()
 
 	fmt.Println("All threads have finished.")
+
+	// A fail-fast smoke test that hit a failure or a test stopped by the error cap fails like a missed SLO
+	if !result.SLOsPassed() || (stopOnFirstError && result.FailedRequests > 0) ||
+		(maxErrors > 0 && result.FailedRequests > maxErrors) {
+		return 1
+	}
+	return 0
 }
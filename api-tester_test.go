@@ -0,0 +1,324 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Environment variable with the arguments, separated by newlines, that makes the test binary run main instead of the
+// tests
+const mainArgsEnv = "API_TESTER_TEST_MAIN_ARGS"
+
+// Function to run main in place of the tests when the test binary is started by runMain
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv(mainArgsEnv); ok {
+		os.Args = append([]string{"api-tester"}, strings.Split(args, "\n")...)
+		os.Exit(run())
+	}
+	os.Exit(m.Run())
+}
+
+// Function to run main in a child process with the arguments and the extra environment variables.  Returns the
+// output and the exit code.
+func runMain(t *testing.T, env []string, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(append(os.Environ(), mainArgsEnv+"="+strings.Join(args, "\n")), env...)
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(output), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("Running main failed: %v", err)
+	}
+	return string(output), 0
+}
+
+// Each flag that takes a value, given as the last argument, must exit with the missing value error and not panic
+func TestMissingFlagValue(t *testing.T) {
+	flags := []string{
+		"-totalCalls", "-numThreads", "-sleepTime", "-requestTimeOut", "-maxRedirects", "-connectTimeOut",
+		"-responseHeaderTimeout", "-oauthTokenURL", "-oauthClientID", "-oauthClientSecret", "-oauthScopes",
+		"-tokenCommand", "-tokenTTL", "-preRequestCommand", "-preRequestTimeout", "-validatorCommand",
+		"-validatorTimeout", "-validatorWorkers", "-preRequestCache", "-rampUp", "-startJitter", "-cacheHeader",
+		"-warmup", "-warmupDuration", "-burst", "-burstPause", "-streams", "-correlationHeader", "-verifyEcho",
+		"-color", "-statusEveryN", "-probeTimeout", "-waitForReady", "-readyTimeout", "-readyInterval",
+		"-hostsFile", "-localPortRange", "-dnsCache", "-localAddresses", "-rebuildOnErrors", "-caCert",
+		"-rotateConnAfter", "-requestsPerConn", "-connectionLifetime", "-latencyTarget", "-adaptInterval",
+		"-maxInflight", "-har", "-maxRPSPerThread", "-timeScale", "-retries", "-retryPolicy", "-retryBackoff",
+		"-retryMaxBackoff", "-ipVersion", "-method", "-target", "-chainURL", "-chainMethod", "-chainBody",
+		"-bodyFromResponse", "-queryFile", "-bodyDir", "-jsonPatch", "-mergePatch", "-jsonBody", "-protoBody",
+		"-protoDescriptor", "-protoMessage", "-repeatBody", "-repeatSize", "-bodySizeMin", "-bodySizeMax",
+		"-maxBodySize", "-success", "-readRate", "-expectHeader", "-assertJSON", "-apdexTarget", "-slaAlert",
+		"-slaWindow", "-maxErrors", "-slo", "-slaFile", "-reportTemplate", "-output", "-summaryCSV", "-traceOut",
+		"-rpsTimelineFile", "-metricsFile", "-influxOut", "-influxUrl", "-influxToken", "-interval",
+		"-exemplarsOut", "-exemplarCount", "-jsonlOut", "-label", "-csvOut", "-summaryInterval", "-jsonOut",
+		"-saveBodies", "-saveBodiesCount", "-worker", "-coordinator",
+	}
+	type testCase struct {
+		name string
+		env  []string
+		args []string
+		flag string
+	}
+	var cases []testCase
+	for _, flag := range flags {
+		cases = append(cases, testCase{flag, nil, []string{"http://x", flag}, flag})
+	}
+	cases = append(cases,
+		testCase{"-workers", nil, []string{"http://x", "-coordinator", "localhost:1", "-workers"}, "-workers"},
+		testCase{"-workerToken", nil, []string{"-worker", "localhost:1", "-workerToken"}, "-workerToken"},
+		// The flags from the environment go before the command line flags, so the last flag is still missing its value
+		testCase{"environment", []string{"APITESTER_THREADS=2", "APITESTER_VERBOSE=1"},
+			[]string{"http://x", "-totalCalls"}, "-totalCalls"},
+		testCase{"environment URL", []string{"APITESTER_URL=http://x"}, []string{"-numThreads"}, "-numThreads"},
+	)
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			output, code := runMain(t, test.env, test.args...)
+			if code != 1 || !strings.Contains(output, "Error: Missing value for "+test.flag+".") {
+				t.Errorf("Args %q exited with %d and output:\n%s", test.args, code, output)
+			}
+		})
+	}
+}
+
+// The calls split across the threads add up to the total calls and differ by at most one, larger counts first
+func TestCallsPerThread(t *testing.T) {
+	cases := []struct {
+		totalCalls int
+		numThreads int
+		split      string
+	}{
+		{1, 1, "1 thread x 1 call"},
+		{10, 1, "1 thread x 10 calls"},
+		{10, 2, "2 threads x 5 calls"},
+		{10, 3, "1 thread x 4 calls + 2 threads x 3 calls"},
+		{11, 4, "3 threads x 3 calls + 1 thread x 2 calls"},
+		{5, 5, "5 threads x 1 call"},
+		{1000, 7, "6 threads x 143 calls + 1 thread x 142 calls"},
+	}
+	for _, test := range cases {
+		calls := callsPerThread(test.totalCalls, test.numThreads)
+		if len(calls) != test.numThreads {
+			t.Errorf("%d calls on %d threads gave %d threads", test.totalCalls, test.numThreads, len(calls))
+		}
+		sum := 0
+		for i, count := range calls {
+			sum += count
+			if i > 0 && (count > calls[i-1] || calls[0]-count > 1) {
+				t.Errorf("%d calls on %d threads gave an uneven split %v", test.totalCalls, test.numThreads, calls)
+				break
+			}
+		}
+		if sum != test.totalCalls {
+			t.Errorf("%d calls on %d threads add up to %d", test.totalCalls, test.numThreads, sum)
+		}
+		if split := describeCallSplit(calls); split != test.split {
+			t.Errorf("%d calls on %d threads described as %q, expected %q", test.totalCalls, test.numThreads, split,
+				test.split)
+		}
+	}
+}
+
+// More threads than calls runs one thread per call and makes exactly the total calls
+func TestMoreThreadsThanCalls(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	jsonOut := filepath.Join(t.TempDir(), "summary.json")
+	output, code := runMain(t, nil, server.URL, "-totalCalls", "5", "-numThreads", "12", "-jsonOut", jsonOut)
+	if code != 0 {
+		t.Fatalf("Exited with %d and output:\n%s", code, output)
+	}
+	if !strings.Contains(output, "Warning: -numThreads 12 is more than -totalCalls 5, using 5 threads.") {
+		t.Errorf("Missing the thread count warning in the output:\n%s", output)
+	}
+	data, err := os.ReadFile(jsonOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Threads != 5 || result.TotalRequests != 5 || requests.Load() != 5 {
+		t.Errorf("Expected 5 threads and 5 requests, got %d threads, %d requests, and %d requests at the server",
+			result.Threads, result.TotalRequests, requests.Load())
+	}
+}
+
+// Cost per request of the sleep between the requests at the default zero sleep time, calling time.Sleep(0) as before
+// and skipping the call as fetchData does now
+func BenchmarkZeroSleep(b *testing.B) {
+	cfg := &testConfig{}
+	b.Run("sleep", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			time.Sleep(cfg.sleepTime)
+		}
+	})
+	b.Run("skip", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if cfg.sleepTime > 0 {
+				time.Sleep(cfg.sleepTime)
+			}
+		}
+	})
+}
+
+// Cancelling the test mid-run counts the requests in flight as cancelled and leaves them out of the failures and the
+// error rate, while a 500 that completed before the cancel is still a failure
+func TestCancelMidRunErrorRate(t *testing.T) {
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			started <- struct{}{}
+			return
+		}
+		started <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	errorRate, err := parseSLO("errors<1%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name      string
+		path      string
+		cause     error
+		cancelled int
+		failed    int
+	}{
+		{"cancelled in flight", "/slow", nil, 1, 0},
+		{"stopped with a cause in flight", "/slow", errors.New("first failed request"), 1, 0},
+		{"500 before the cancel", "/fail", nil, 0, 1},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			// The 500 is retried after a long backoff, so the cancel comes after the response, during the wait
+			cfg := &testConfig{retries: 1, retryPolicy: "fixed", retryBackoff: time.Minute, successRequired: true}
+			cfg.success, _ = parsePredicate(defaultSuccess)
+			stats := &testStats{}
+			stats.record(&requestResult{StatusCode: 200, ResponseTime: 10})
+
+			ctx, abort := context.WithCancelCause(context.Background())
+			defer abort(nil)
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			done := make(chan requestResult)
+			go func() {
+				done <- doRequest(server.Client(), request, cfg, stats, nil)
+			}()
+			<-started
+			for test.path == "/fail" && stats.inFlight.Load() != 0 {
+				time.Sleep(time.Millisecond)
+			}
+			abort(test.cause)
+			result := <-done
+			stats.record(&result)
+
+			summary := stats.summarize(server.URL, 1, 1)
+			if summary.CancelledRequests != test.cancelled || summary.FailedRequests != test.failed {
+				t.Errorf("Expected %d cancelled and %d failed, got %d cancelled and %d failed (%v)", test.cancelled,
+					test.failed, summary.CancelledRequests, summary.FailedRequests, result.err)
+			}
+			expected := float64(test.failed) * 100 / float64(1+test.failed)
+			if rate := errorRate.measure(nil, &summary); rate != expected {
+				t.Errorf("Expected an error rate of %.2f%%, got %.2f%%", expected, rate)
+			}
+		})
+	}
+}
+
+// The query and body flags do not apply to the targets of a -target file, which have their own, so they are rejected
+func TestTargetRejectsBodyFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-queryFile", "queries.txt"},
+		{"-bodyDir", "bodies"},
+		{"-jsonPatch", "patch.json"},
+		{"-bodySizeMin", "10", "-bodySizeMax", "20"},
+		{"-repeatBody", "x"},
+		{"-jsonBody", "{}"},
+		{"-protoBody", "{}"},
+	} {
+		output, code := runMain(t, nil, append([]string{"-target", "targets.txt"}, args...)...)
+		if code != 1 || !strings.Contains(output, "Error: -target cannot be used with") {
+			t.Errorf("Args %q exited with %d and output:\n%s", args, code, output)
+		}
+	}
+}
+
+// Argument errors exit with 1, like a missing flag value, and the help exits with 0
+func TestArgumentErrorExitCode(t *testing.T) {
+	cases := []struct {
+		args   []string
+		code   int
+		output string
+	}{
+		{[]string{""}, 1, "is not a valid URL"},
+		{[]string{"ftp://x"}, 1, "is not a valid URL"},
+		{[]string{"http://x", "-numThreads", "many"}, 1, "Error: \"many\" is not a valid integer."},
+		{[]string{"http://x", "-retries", "-1"}, 1, "Error: \"-1\" is not a valid integer."},
+		{[]string{"http://x", "-color", "sometimes"}, 1, "is not a valid color mode"},
+		{[]string{"http://x", "-slaFile", filepath.Join(t.TempDir(), "missing.txt")}, 1, "Error: Reading the SLA file"},
+		{[]string{"https://x", "-randomizeHeaders"}, 1, "Error: -randomizeHeaders cannot be used with an https URL."},
+		{[]string{"http://x", "-randomizeHeaders", "-chainURL", "HTTPS://x/next"}, 1,
+			"Error: -randomizeHeaders cannot be used with an https URL."},
+		{[]string{"-?"}, 0, "Usage"},
+	}
+	for _, test := range cases {
+		output, code := runMain(t, nil, test.args...)
+		if code != test.code || !strings.Contains(output, test.output) {
+			t.Errorf("Args %q exited with %d, expected %d with %q, and output:\n%s", test.args, code, test.code,
+				test.output, output)
+		}
+	}
+}
+
+// A failed connection probe or readiness wait exits before the per-request outputs are created
+func TestSetupFailureLeavesNoOutputs(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	closedURL := server.URL
+	server.Close()
+
+	for _, args := range [][]string{
+		{closedURL},
+		{closedURL, "-ignoreProbe", "-waitForReady", closedURL, "-readyTimeout", "1", "-readyInterval", "100"},
+	} {
+		dir := t.TempDir()
+		csvOut, jsonlOut := filepath.Join(dir, "requests.csv.gz"), filepath.Join(dir, "requests.jsonl")
+		output, code := runMain(t, nil, append(args, "-totalCalls", "1", "-csvOut", csvOut, "-jsonlOut", jsonlOut)...)
+		if code != 1 {
+			t.Errorf("Args %q exited with %d and output:\n%s", args, code, output)
+		}
+		for _, fileName := range []string{csvOut, jsonlOut} {
+			if _, err := os.Stat(fileName); err == nil {
+				t.Errorf("Args %q left the output %s", args, fileName)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Function to load the backend addresses from a -hostsFile, one IP or host per line with an optional port.  Blank
+// lines and lines starting with "#" are skipped.
+func loadHostsFile(fileName string) ([]string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var hosts []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hosts = append(hosts, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts in the file")
+	}
+	return hosts, nil
+}
+
+// Function to get the dial address for the next backend in rotation.  The port of the original address is kept
+// unless the backend has its own.
+func (d *connDialer) backendAddress(address string) string {
+	backend := d.hosts[(d.nextHost.Add(1)-1)%uint64(len(d.hosts))]
+	if _, _, err := net.SplitHostPort(backend); err == nil {
+		return backend
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return backend
+	}
+	return net.JoinHostPort(strings.Trim(backend, "[]"), port)
+}
+
+// Function to print the response time statistics for each -hostsFile backend
+func printBackendStats(backendStats map[string]*LatencySummary) {
+	backends := make([]string, 0, len(backendStats))
+	for backend := range backendStats {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+
+	for _, backend := range backends {
+		summary := backendStats[backend]
+		fmt.Printf("Backend %s - Count: %d - Average: %.2f ms - Min: %.2f ms - Max: %.2f ms\n", backend, summary.Count,
+			summary.AverageResponseTime, summary.MinResponseTime, summary.MaxResponseTime)
+	}
+}
@@ -0,0 +1,168 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Content types of the -jsonPatch and -mergePatch request bodies
+const (
+	jsonPatchContentType  = "application/json-patch+json"
+	mergePatchContentType = "application/merge-patch+json"
+)
+
+// Request body loaded from a -bodyDir file
+type bodyFile struct {
+	name string
+	data []byte
+}
+
+// Function to load a -jsonPatch or -mergePatch request body and check that it is well-formed JSON
+func loadPatchFile(fileName string) (bodyFile, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return bodyFile{}, err
+	}
+	if !json.Valid(data) {
+		return bodyFile{}, fmt.Errorf("the file is not valid JSON")
+	}
+	return bodyFile{name: filepath.Base(fileName), data: data}, nil
+}
+
+// Function to load a -jsonBody, the JSON itself or "@file" for the contents of the file, and check that it is
+// well-formed JSON
+func loadJSONBody(value string) (bodyFile, error) {
+	if strings.HasPrefix(value, "@") {
+		return loadPatchFile(value[1:])
+	}
+	if !json.Valid([]byte(value)) {
+		return bodyFile{}, fmt.Errorf("the body is not valid JSON")
+	}
+	return bodyFile{data: []byte(value)}, nil
+}
+
+// Function to load every regular file in the directory as a request body, sorted by file name
+func loadBodyDir(dir string) ([]bodyFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []bodyFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, bodyFile{name: entry.Name(), data: data})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	return files, nil
+}
+
+// Function to choose the body file for a request
+func chooseBodyFile(cfg *testConfig, threadID int, iteration int) *bodyFile {
+	if cfg.bodyRandom {
+		return &cfg.bodyFiles[rand.IntN(len(cfg.bodyFiles))]
+	}
+	return &cfg.bodyFiles[roundRobin(threadID, iteration, len(cfg.bodyFiles))]
+}
+
+// Function to load a -repeatBody template, the text itself or "@file" for the contents of the file, and repeat it
+// until the body is size bytes.  The last copy is cut short to fit.
+func repeatedBody(value string, size int) (bodyFile, error) {
+	template := []byte(value)
+	if strings.HasPrefix(value, "@") {
+		var err error
+		if template, err = os.ReadFile(value[1:]); err != nil {
+			return bodyFile{}, err
+		}
+	}
+	if len(template) == 0 {
+		return bodyFile{}, fmt.Errorf("the body template is empty")
+	}
+	data := bytes.Repeat(template, size/len(template)+1)
+	return bodyFile{name: "repeated", data: data[:size]}, nil
+}
+
+// Read buffers for -noBodyRead, reused by the requests so draining a response allocates nothing
+var drainBuffers = sync.Pool{New: func() any {
+	buffer := make([]byte, 32*1024)
+	return &buffer
+}}
+
+// Function to read a response body to the end with a pooled buffer.  Returns the number of bytes read.
+func drainBody(body io.Reader) (int64, error) {
+	buffer := drainBuffers.Get().(*[]byte)
+	defer drainBuffers.Put(buffer)
+	var total int64
+	for {
+		n, err := body.Read(*buffer)
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		} else if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Request body size and response time of a -bodySizeMin to -bodySizeMax request
+type bodySizeSample struct {
+	size         float64
+	responseTime float64
+}
+
+// Function to set a filler body with a random size from -bodySizeMin to -bodySizeMax.  Returns the size.
+func setRandomSizeBody(request *http.Request, cfg *testConfig) int {
+	size := cfg.bodySizeMin + rand.IntN(cfg.bodySizeMax-cfg.bodySizeMin+1)
+	setRequestBody(request, cfg.bodyFiller[:size])
+	return size
+}
+
+// Function to calculate the Pearson correlation of two equal length series.  Returns zero when either is constant.
+func correlation(xs []float64, ys []float64) float64 {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var covariance, varianceX, varianceY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		covariance += dx * dy
+		varianceX += dx * dx
+		varianceY += dy * dy
+	}
+	if varianceX == 0 || varianceY == 0 {
+		return 0
+	}
+	return covariance / math.Sqrt(varianceX*varianceY)
+}
+
+// Function to set a new body reader on a request that is reused for every call
+func setRequestBody(request *http.Request, data []byte) {
+	request.ContentLength = int64(len(data))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	request.Body, _ = request.GetBody()
+}
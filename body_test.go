@@ -0,0 +1,87 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Size of the response body of the benchmarks, a small API response
+const benchmarkBodySize = 2 * 1024
+
+// Reader of a body that hides the WriterTo of bytes.Reader, like an HTTP response body
+type benchmarkBody struct {
+	reader *bytes.Reader
+}
+
+// Function to read the next bytes of the body
+func (body *benchmarkBody) Read(p []byte) (int, error) {
+	return body.reader.Read(p)
+}
+
+// Allocations of draining a body with the pooled buffer of -noBodyRead and with the reads it replaces
+func BenchmarkDrainBody(b *testing.B) {
+	data := bytes.Repeat([]byte("x"), benchmarkBodySize)
+	drains := []struct {
+		name  string
+		drain func(io.Reader) (int64, error)
+	}{
+		{"pooled", drainBody},
+		{"discard", func(body io.Reader) (int64, error) { return io.Copy(io.Discard, body) }},
+		{"readAll", func(body io.Reader) (int64, error) {
+			read, err := io.ReadAll(body)
+			return int64(len(read)), err
+		}},
+	}
+	for _, drain := range drains {
+		b.Run(drain.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(benchmarkBodySize)
+			body := &benchmarkBody{reader: bytes.NewReader(data)}
+			for i := 0; i < b.N; i++ {
+				body.reader.Reset(data)
+				if _, err := drain.drain(body); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Allocations of a request to a local server, reading the body to discard it and with -noBodyRead
+func BenchmarkDoRequest(b *testing.B) {
+	data := bytes.Repeat([]byte("x"), benchmarkBodySize)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	for _, noBodyRead := range []bool{false, true} {
+		name := "readBody"
+		if noBodyRead {
+			name = "noBodyRead"
+		}
+		b.Run(name, func(b *testing.B) {
+			cfg := &testConfig{noBodyRead: noBodyRead}
+			cfg.success, _ = parsePredicate(defaultSuccess)
+			stats := &testStats{}
+			httpClient := server.Client()
+			request, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if result := doRequest(httpClient, request, cfg, stats, nil); result.err != nil {
+					b.Fatal(result.err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Default header of a CDN or proxy cache result, like "HIT" or "MISS from proxy"
+const defaultCacheHeader = "X-Cache"
+
+// Cache results of the recorded responses for -cacheHeader.  Guarded by the output mutex.
+type cacheStats struct {
+	header string
+	hits   int
+	misses int
+	// Responses without the header
+	missing int
+}
+
+// Function to count the cache result of a response.  A header value with "HIT" in any case is a hit and any other
+// value a miss, which covers X-Cache, CF-Cache-Status, and most proxies.
+func (cache *cacheStats) add(header http.Header) {
+	value := header.Get(cache.header)
+	switch {
+	case value == "":
+		cache.missing++
+	case strings.Contains(strings.ToUpper(value), "HIT"):
+		cache.hits++
+	default:
+		cache.misses++
+	}
+}
+
+// Function to get the percentage of the responses that were cache hits
+func (cache *cacheStats) hitRate() float64 {
+	total := cache.hits + cache.misses + cache.missing
+	if total == 0 {
+		return 0
+	}
+	return float64(cache.hits) * 100 / float64(total)
+}
+
+// Function to get the number of unrecorded -cacheWarm calls a thread makes to send each request of its measured calls
+// once.  The query strings and body files are chosen round-robin from the thread number, so the thread's first calls
+// repeat in its measured calls.
+func cacheWarmCalls(cfg *testConfig, numCalls int) int {
+	variants := max(len(cfg.queries), 1)
+	if !cfg.bodyRandom && len(cfg.bodyFiles) > variants {
+		variants = len(cfg.bodyFiles)
+	}
+	return min(numCalls, variants)
+}
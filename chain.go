@@ -0,0 +1,187 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default -bodyFromResponse source, the URL of a created resource
+const defaultChainSource = "header:Location"
+
+// Placeholder in the -chainURL and -chainBody replaced by the value from the first response
+const chainPlaceholder = "{{value}}"
+
+// Second request of a -chainURL two-step flow, like a GET of the resource a POST created.  It is sent after each
+// request of a thread iteration with a value taken from the first response.
+type responseChain struct {
+	// Response header with the value, or the JSONPath into the response body when the value is in the body
+	header    string
+	path      []any
+	needsBody bool
+	method    string
+	url       string
+	body      string
+}
+
+// Statistics of the two -chainURL steps
+type chainStats struct {
+	// Names of the steps in the summary
+	names    [2]string
+	steps    [2]LatencySummary
+	failures [2]int
+	// First requests that failed or had no value, so the second request was not sent
+	skipped int
+}
+
+// Response time statistics of a -chainURL step in the summary
+type ChainStep struct {
+	Step    int    `json:"step"`
+	Request string `json:"request"`
+	Failed  int    `json:"failed"`
+	LatencySummary
+}
+
+// Function to create the -chainURL step.  The source is "header:Name" or "json:$.path", and the URL and body can
+// have the {{value}} placeholder.
+func newResponseChain(source string, method string, url string, body string) (*responseChain, error) {
+	chain := &responseChain{method: strings.ToUpper(method), url: url, body: body}
+	kind, name, _ := strings.Cut(source, ":")
+	switch {
+	case kind == "header" && name != "":
+		chain.header = http.CanonicalHeaderKey(strings.TrimSpace(name))
+	case kind == "json":
+		path, err := parseJSONPath(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		chain.path = path
+		chain.needsBody = true
+	default:
+		return nil, fmt.Errorf("expected \"header:Name\" or \"json:$.path\"")
+	}
+	return chain, nil
+}
+
+// Function to take the value from the first response.  A JSON object or array is the JSON text.  Returns false when
+// the response does not have the value.
+func (chain *responseChain) value(first *requestResult, body *bytes.Buffer) (string, bool) {
+	if !chain.needsBody {
+		value := first.header.Get(chain.header)
+		return value, value != ""
+	}
+	var document any
+	if body == nil || json.Unmarshal(body.Bytes(), &document) != nil {
+		return "", false
+	}
+	value, found := lookupJSONPath(document, chain.path)
+	if !found {
+		return "", false
+	}
+	switch value := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return value, value != ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(value), true
+	default:
+		data, err := json.Marshal(value)
+		return string(data), err == nil
+	}
+}
+
+// Function to send and record the second request of the thread iteration with the value from the first response.
+// A relative URL, like a Location header, is resolved against the first request URL.  The caller must not hold the
+// output mutex.
+func (chain *responseChain) follow(ctx context.Context, mu *sync.Mutex, httpClient *http.Client, stats *testStats,
+	cfg *testConfig, threadID int, iteration int, first *requestResult, firstBody *bytes.Buffer) {
+	value, ok := "", false
+	if first.err == nil {
+		value, ok = chain.value(first, firstBody)
+	}
+	if !ok {
+		mu.Lock()
+		stats.chain.skipped++
+		mu.Unlock()
+		return
+	}
+
+	base, err := url.Parse(first.URL)
+	var target *url.URL
+	if err == nil {
+		target, err = base.Parse(strings.ReplaceAll(chain.url, chainPlaceholder, value))
+	}
+	var body io.Reader
+	if chain.body != "" {
+		body = strings.NewReader(strings.ReplaceAll(chain.body, chainPlaceholder, value))
+	}
+	var request *http.Request
+	if err == nil {
+		request, err = http.NewRequestWithContext(ctx, chain.method, target.String(), body)
+	}
+	if err != nil {
+		mu.Lock()
+		fmt.Printf("Error:  Chained request creation failed for thread %2d: %v\n", threadID, err)
+		stats.chain.skipped++
+		mu.Unlock()
+		return
+	}
+	setConnectionHeader(request, cfg.reuseConnects)
+	if body != nil {
+		if cfg.contentType != "" {
+			request.Header.Set("Content-Type", cfg.contentType)
+		} else if json.Valid([]byte(chain.body)) {
+			request.Header.Set("Content-Type", "application/json")
+		}
+	}
+
+	result := doRequest(httpClient, request, cfg, stats, nil)
+	result.ThreadID = threadID
+	result.Iteration = iteration
+	result.Step = 2
+
+	mu.Lock()
+	printResult(&result, cfg)
+	stats.record(&result)
+	mu.Unlock()
+}
+
+// Function to add a request to the statistics of its step
+func (stats *chainStats) add(result *requestResult) {
+	stats.steps[result.Step-1].add(result.ResponseTime)
+	if result.err != nil {
+		stats.failures[result.Step-1]++
+	}
+}
+
+// Function to get the statistics of the two steps for the summary
+func (stats *chainStats) summary() []ChainStep {
+	steps := make([]ChainStep, len(stats.steps))
+	for i := range stats.steps {
+		steps[i] = ChainStep{Step: i + 1, Request: stats.names[i], Failed: stats.failures[i],
+			LatencySummary: stats.steps[i]}
+	}
+	return steps
+}
+
+// Function to print the response time statistics of each -chainURL step and the second requests not sent
+func printChainSteps(steps []ChainStep, skipped int) {
+	for _, step := range steps {
+		fmt.Printf("Step %d %s - Count: %d - Failed: %d - Average: %.2f ms - Min: %.2f ms - Max: %.2f ms\n", step.Step,
+			step.Request, step.Count, step.Failed, step.AverageResponseTime, step.MinResponseTime, step.MaxResponseTime)
+	}
+	fmt.Printf("Chained requests skipped for a failed first request or a missing value: %d\n", skipped)
+}
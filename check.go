@@ -0,0 +1,70 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+)
+
+// Transport that prints every request and response on the wire for -checkOnly, including redirects and retries
+type dumpTransport struct {
+	base http.RoundTripper
+}
+
+// Function to print the request, send it with the base transport, and print the response
+func (transport *dumpTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if dump, err := httputil.DumpRequestOut(request, true); err == nil {
+		fmt.Printf("--- Request ---\n%s\n", dump)
+	}
+	resp, err := transport.base.RoundTrip(request)
+	if err != nil {
+		fmt.Printf("--- No response ---\n%v\n", err)
+		return resp, err
+	}
+	if dump, err := httputil.DumpResponse(resp, true); err == nil {
+		fmt.Printf("--- Response ---\n%s\n", dump)
+	}
+	return resp, nil
+}
+
+// Function to send a single request with the request and response printed in full and print whether it passed the
+// success criteria.  Returns true when it passed.
+func checkOnly(ctx context.Context, httpClient *http.Client, stats *testStats, url string, method string,
+	cfg *testConfig) bool {
+	request, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		fmt.Printf("Error:  Request creation failed: %v\n", err)
+		return false
+	}
+	setConnectionHeader(request, cfg.reuseConnects)
+	if cfg.contentType != "" {
+		request.Header.Set("Content-Type", cfg.contentType)
+	}
+	request, prepared, err := prepareRequest(ctx, request, request.URL.RawQuery, cfg, 0, 0)
+	if err != nil {
+		fmt.Printf("Error:  Request creation failed: %v\n", err)
+		return false
+	}
+	defer prepared.release()
+
+	client := *httpClient
+	client.Transport = &dumpTransport{base: httpClient.Transport}
+	result := doRequest(&client, request, cfg, stats, &bytes.Buffer{})
+	if prepared.target != nil {
+		checkStatus(&result, prepared.target.ExpectStatus)
+	}
+
+	if result.err != nil {
+		fmt.Printf("FAIL: %v - Status: %d - Response time: %.2f ms\n", result.err, result.StatusCode,
+			result.ResponseTime)
+		return false
+	}
+	fmt.Printf("PASS: %d %s - Response time: %.2f ms\n", result.StatusCode, http.StatusText(result.StatusCode),
+		result.ResponseTime)
+	return true
+}
@@ -0,0 +1,20 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import "time"
+
+// Response times are measured from a time.Now() reading to time.Since, which subtracts the monotonic clock readings.
+// Wall clock changes, like NTP adjustments, do not affect the result.  The start time must not go through Round,
+// Truncate, UTC, or a Unix conversion, which drop the monotonic reading.
+
+// Function to convert a duration to milliseconds with microsecond precision
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
+
+// Function to get the milliseconds elapsed since a time.Now() start time on the monotonic clock.  Never negative.
+func millisecondsSince(startTime time.Time) float64 {
+	return milliseconds(max(0, time.Since(startTime)))
+}
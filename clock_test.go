@@ -0,0 +1,29 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// An elapsed time is never negative, even from a start time that lost its monotonic reading and is ahead of the wall
+// clock, like after a clock step back
+func TestMillisecondsSinceNeverNegative(t *testing.T) {
+	for _, ahead := range []time.Duration{0, time.Millisecond, time.Second, time.Hour} {
+		startTime := time.Now().Round(0).Add(ahead)
+		if elapsed := millisecondsSince(startTime); elapsed < 0 {
+			t.Errorf("Start time %v ahead gave %.3f ms", ahead, elapsed)
+		}
+	}
+}
+
+// A start time with its monotonic reading measures the elapsed time
+func TestMillisecondsSince(t *testing.T) {
+	startTime := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if elapsed := millisecondsSince(startTime); elapsed < 10 {
+		t.Errorf("Expected at least 10 ms, got %.3f ms", elapsed)
+	}
+}
@@ -0,0 +1,49 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// ANSI escape codes for the -color output
+const (
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+	ansiReset = "\033[0m"
+)
+
+// Function to check if the standard output is a terminal rather than a file or a pipe.  The null device is a
+// character device too, so it is told apart from a terminal by comparing it with os.DevNull.
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// Function to decide from the -color mode if the output is colored.  The "auto" mode colors only a terminal, and
+// not when the NO_COLOR environment variable is set.
+func useColor(mode string) (bool, error) {
+	switch mode {
+	case "auto":
+		return stdoutIsTerminal() && os.Getenv("NO_COLOR") == "", nil
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	}
+	return false, fmt.Errorf("\"%s\" is not a valid color mode", mode)
+}
+
+// Function to wrap the text in the color escape codes when the output is colored
+func colorize(cfg *testConfig, text string, color string) string {
+	if !cfg.color {
+		return text
+	}
+	return color + text + ansiReset
+}
@@ -0,0 +1,29 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Output redirected to the null device or a file is not a terminal, so the auto mode does not color it
+func TestStdoutIsTerminalRedirected(t *testing.T) {
+	stdout := os.Stdout
+	defer func() { os.Stdout = stdout }()
+	for _, name := range []string{os.DevNull, filepath.Join(t.TempDir(), "output.txt")} {
+		file, err := os.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Stdout = file
+		terminal := stdoutIsTerminal()
+		os.Stdout = stdout
+		file.Close()
+		if terminal {
+			t.Errorf("Output to %s detected as a terminal", name)
+		}
+	}
+}
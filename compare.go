@@ -0,0 +1,197 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Test run of a comparison with the name it is shown under and its full arguments
+type comparePhase struct {
+	name string
+	args []string
+}
+
+// JSON summary of a comparison for -jsonOut, with the summaries of the two phases and the changes between them
+type Comparison struct {
+	Phases []ComparisonPhase `json:"phases"`
+	Deltas []ComparisonDelta `json:"deltas"`
+}
+
+// Summary of a comparison phase
+type ComparisonPhase struct {
+	Name   string `json:"name"`
+	Result Result `json:"result"`
+}
+
+// Change of a compared value from the first phase to the second.  The percent is left out when the first is zero.
+type ComparisonDelta struct {
+	Name    string   `json:"name"`
+	First   float64  `json:"first"`
+	Second  float64  `json:"second"`
+	Delta   float64  `json:"delta"`
+	Percent *float64 `json:"percent,omitempty"`
+}
+
+// Function to run the test twice, with keep-alive and without, and print the two summaries side by side.  Returns the
+// exit code, which is the highest exit code of the phases.
+func compareKeepAlive(args []string) int {
+	// The phases choose the keep-alive setting and write their summaries to the temporary directory
+	var phaseArgs []string
+	jsonOut := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-compareKeepAlive", "-reuseConnects":
+		case "-jsonOut":
+			if i++; i >= len(args) {
+				fmt.Println("Error: Missing value for -jsonOut.")
+				return 1
+			}
+			jsonOut = args[i]
+		default:
+			phaseArgs = append(phaseArgs, args[i])
+		}
+	}
+
+	return runComparison([]comparePhase{
+		{"Keep-alive on", append(slices.Clone(phaseArgs), "-reuseConnects")},
+		{"Keep-alive off", phaseArgs},
+	}, jsonOut)
+}
+
+// Function to run the same test against the two -compareTargets URLs, one after the other, and print the two
+// summaries side by side, like a canary against the stable endpoint.  Returns the exit code, which is the highest
+// exit code of the phases.
+func compareTargets(args []string) int {
+	var urls, phaseArgs []string
+	jsonOut := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-compareTargets":
+			urls = args[i+1 : min(i+3, len(args))]
+			i += len(urls)
+		case args[i] == "-jsonOut":
+			if i++; i >= len(args) {
+				fmt.Println("Error: Missing value for -jsonOut.")
+				return 1
+			}
+			jsonOut = args[i]
+		case i == 0 && strings.HasPrefix(args[i], "http"):
+			// The test URL is replaced by each target URL
+		default:
+			phaseArgs = append(phaseArgs, args[i])
+		}
+	}
+	if len(urls) != 2 || !strings.HasPrefix(urls[0], "http") || !strings.HasPrefix(urls[1], "http") {
+		fmt.Println("Error: -compareTargets requires two URLs.")
+		return 1
+	}
+
+	fmt.Printf("Target A: %s\nTarget B: %s\n", urls[0], urls[1])
+	return runComparison([]comparePhase{
+		{"Target A", append([]string{urls[0]}, phaseArgs...)},
+		{"Target B", append([]string{urls[1]}, phaseArgs...)},
+	}, jsonOut)
+}
+
+// Function to run the two phases and print their summaries side by side, and write them with the deltas to the
+// -jsonOut file when it is set.  Each phase runs as a separate process so the phases share no connections or state.
+// Returns the exit code, which is the highest exit code of the phases.
+func runComparison(phases []comparePhase, jsonOut string) int {
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Printf("Error: Finding the api-tester executable failed: %v\n", err)
+		return 1
+	}
+	tempDir, err := os.MkdirTemp("", "api-tester-compare")
+	if err != nil {
+		fmt.Printf("Error: Creating the comparison directory failed: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The phases write their summaries to the temporary directory
+	results := make([]Result, len(phases))
+	exitCode := 0
+	for i, phase := range phases {
+		fmt.Printf("==================== %s ====================\n", phase.name)
+		jsonFile := filepath.Join(tempDir, fmt.Sprintf("phase%d.json", i+1))
+		cmd := exec.Command(executable, append(slices.Clone(phase.args), "-jsonOut", jsonFile)...)
+		cmd.Env = childEnv()
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				fmt.Printf("Error: Running the %s phase failed: %v\n", phase.name, err)
+				return 1
+			}
+			exitCode = max(exitCode, exitErr.ExitCode())
+		}
+		data, err := os.ReadFile(jsonFile)
+		if err == nil {
+			err = json.Unmarshal(data, &results[i])
+		}
+		if err != nil {
+			fmt.Printf("Error: Reading the %s summary failed: %v\n", phase.name, err)
+			return max(exitCode, 1)
+		}
+	}
+
+	deltas := compareResults(&results[0], &results[1])
+	printComparison(phases[0].name, phases[1].name, deltas)
+	if jsonOut != "" {
+		comparison := Comparison{Deltas: deltas}
+		for i, phase := range phases {
+			comparison.Phases = append(comparison.Phases, ComparisonPhase{Name: phase.name, Result: results[i]})
+		}
+		if err := writeJSON(jsonOut, &comparison); err != nil {
+			fmt.Printf("Error: Writing the JSON summary \"%s\" failed: %v\n", jsonOut, err)
+			return max(exitCode, 1)
+		}
+	}
+	return exitCode
+}
+
+// Function to get the compared values of the two summaries with the change from the first to the second
+func compareResults(first *Result, second *Result) []ComparisonDelta {
+	deltas := []ComparisonDelta{
+		{Name: "Requests per second", First: first.RequestsPerSecond, Second: second.RequestsPerSecond},
+		{Name: "Average response time (ms)", First: first.AverageResponseTime, Second: second.AverageResponseTime},
+		{Name: "p50 response time (ms)", First: first.P50ResponseTime, Second: second.P50ResponseTime},
+		{Name: "p95 response time (ms)", First: first.P95ResponseTime, Second: second.P95ResponseTime},
+		{Name: "p99 response time (ms)", First: first.P99ResponseTime, Second: second.P99ResponseTime},
+		{Name: "Failed requests", First: float64(first.FailedRequests), Second: float64(second.FailedRequests)},
+		{Name: "Connections opened", First: float64(first.ConnectionsOpened),
+			Second: float64(second.ConnectionsOpened)},
+	}
+	for i := range deltas {
+		delta := &deltas[i]
+		delta.Delta = delta.Second - delta.First
+		if delta.First != 0 {
+			percent := delta.Delta / delta.First * 100
+			delta.Percent = &percent
+		}
+	}
+	return deltas
+}
+
+// Function to print two test summaries side by side with the change from the first to the second
+func printComparison(firstName string, secondName string, deltas []ComparisonDelta) {
+	fmt.Println("==================== Comparison ====================")
+	fmt.Printf("%-28s %16s %16s   %s\n", "", firstName, secondName, "Delta")
+	for _, row := range deltas {
+		delta := fmt.Sprintf("%+.2f", row.Delta)
+		if row.Percent != nil {
+			delta += fmt.Sprintf(" (%+.1f%%)", *row.Percent)
+		}
+		fmt.Printf("%-28s %16.2f %16.2f   %s\n", row.Name, row.First, row.Second, delta)
+	}
+}
@@ -0,0 +1,65 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Connection ages for -connectionLifetime.  Has its own mutex because the trace hooks run without the output mutex.
+type connLifetime struct {
+	lifetime time.Duration
+	mu       sync.Mutex
+	// Time each open connection was first used, which is when it was opened
+	opened map[net.Conn]time.Time
+	// Connections closed for their age
+	recycled int
+}
+
+// Function to create the connection age limit
+func newConnLifetime(lifetime time.Duration) *connLifetime {
+	return &connLifetime{lifetime: lifetime, opened: make(map[net.Conn]time.Time)}
+}
+
+// Function to track the age of the connection the request gets.  A request on a connection older than the lifetime
+// is sent with "Connection: close" so the connection is replaced after it, whatever its idle time.  Returns a copy of
+// the request because the threads reuse their request and the header must not stay on it.
+func (age *connLifetime) withTrace(request *http.Request, use *connUse) *http.Request {
+	var traced *http.Request
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			age.mu.Lock()
+			defer age.mu.Unlock()
+			use.conn = info.Conn
+			opened, found := age.opened[info.Conn]
+			if !found {
+				age.opened[info.Conn] = time.Now()
+				return
+			}
+			if time.Since(opened) >= age.lifetime {
+				// The transport writes the request after GotConn, so the header still goes out
+				traced.Close = true
+				traced.Header.Set("Connection", "close")
+				delete(age.opened, info.Conn)
+				age.recycled++
+			}
+		},
+	}
+	traced = request.Clone(httptrace.WithClientTrace(request.Context(), trace))
+	return traced
+}
+
+// Function to forget a connection the server closed or that failed so the map only holds open connections
+func (age *connLifetime) done(use *connUse, result *requestResult) {
+	if use.conn == nil || (result.err == nil && !result.serverClose) {
+		return
+	}
+	age.mu.Lock()
+	delete(age.opened, use.conn)
+	age.mu.Unlock()
+}
@@ -0,0 +1,120 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Function to note when the request gets a connection, so a failed request can be told apart from one that never
+// connected because the dial or the TLS handshake failed
+func trackConnection(request *http.Request, connected *bool) *http.Request {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { *connected = true },
+	}
+	return request.WithContext(httptrace.WithClientTrace(request.Context(), trace))
+}
+
+// Function to get the host:port dial address for a URL, adding the default port for the scheme
+func dialAddress(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "80"
+		if parsed.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(parsed.Hostname(), port), nil
+}
+
+// Function to open and close connections without sending a request and measure the dial and TLS handshake time.
+// The TLS config is nil for plain TCP connections.
+func connectData(ctx context.Context, wg *sync.WaitGroup, mu *sync.Mutex, stats *testStats, dialer *connDialer,
+	address string, tlsConfig *tls.Config, cfg *testConfig, threadID int, numCalls int) {
+	defer wg.Done()
+
+	for i := 0; i < numCalls && ctx.Err() == nil; i++ {
+		result := requestResult{ThreadID: threadID, Iteration: i, URL: address}
+
+		startTime := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		remoteAddress := address
+		if err == nil {
+			remoteAddress = conn.RemoteAddr().String()
+		}
+		if err == nil && tlsConfig != nil {
+			tlsConn := tls.Client(conn, tlsConfig)
+			handshakeStart := time.Now()
+			err = tlsConn.HandshakeContext(ctx)
+			if err == nil {
+				stats.handshakes.add(time.Since(handshakeStart), tlsConn.ConnectionState())
+			}
+			conn = tlsConn
+		}
+		result.ResponseTime = millisecondsSince(startTime)
+		if conn != nil {
+			_ = conn.Close()
+		}
+		if err != nil {
+			err = describeTLSError(err)
+			result.err = err
+			result.Error = err.Error()
+			result.Cancelled = errors.Is(err, context.Canceled) || ctx.Err() != nil
+		}
+
+		mu.Lock()
+		// Only the failures are printed with -dumpFailuresOnly
+		if !cfg.failuresOnly || (result.err != nil && !result.Cancelled) {
+			printConnectResult(&result, remoteAddress)
+		}
+		stats.record(&result)
+		mu.Unlock()
+
+		if cfg.sleepTime > 0 {
+			time.Sleep(cfg.sleepTime)
+		}
+	}
+}
+
+// Function to print the per-connection result line.  The caller must hold the output mutex.
+func printConnectResult(result *requestResult, remoteAddress string) {
+	if result.Cancelled {
+		fmt.Printf("Thread %2d.%-6d - Connect cancelled\n", result.ThreadID, result.Iteration)
+	} else if result.err != nil {
+		fmt.Printf("Thread %2d.%-6d - Connect failed: %v - Address: %s - Connect time: %.2f ms\n", result.ThreadID,
+			result.Iteration, result.err, remoteAddress, result.ResponseTime)
+	} else {
+		fmt.Printf("Thread %2d.%-6d - Connected: %s - Connect time: %.2f ms\n", result.ThreadID, result.Iteration,
+			remoteAddress, result.ResponseTime)
+	}
+}
+
+// Function to check that the URL host accepts a TCP connection before the test starts, so an unreachable host fails
+// at once instead of with every request
+func probeConnect(ctx context.Context, dialer *connDialer, rawURL string, timeout time.Duration) error {
+	address, err := dialAddress(rawURL)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,85 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+)
+
+// Per-connection request counts for -requestsPerConn.  Has its own mutex because the trace hooks run without the
+// output mutex.
+type connRequestLimit struct {
+	limit  int
+	mu     sync.Mutex
+	counts map[net.Conn]int
+	// Connections the client closed at the limit
+	limited int
+	// Requests at the limit that the server also answered with "Connection: close", or that failed
+	serverClosedAtLimit int
+	failedAtLimit       int
+	// Connections the server closed before the limit and the most requests one of them served
+	serverClosedEarly int
+	maxEarlyRequests  int
+}
+
+// Connection use of a single request attempt
+type connUse struct {
+	conn  net.Conn
+	count int
+}
+
+// Function to create the per-connection request limit
+func newConnRequestLimit(limit int) *connRequestLimit {
+	return &connRequestLimit{limit: limit, counts: make(map[net.Conn]int)}
+}
+
+// Function to count the request on the connection it gets.  The request that reaches the limit is sent with
+// "Connection: close" so the next request on that thread opens a new connection.  Returns a copy of the request
+// because the threads reuse their request and the header must not stay on it.
+func (limit *connRequestLimit) withTrace(request *http.Request, use *connUse) *http.Request {
+	var traced *http.Request
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			limit.mu.Lock()
+			defer limit.mu.Unlock()
+			limit.counts[info.Conn]++
+			use.conn = info.Conn
+			use.count = limit.counts[info.Conn]
+			if use.count >= limit.limit {
+				// The transport writes the request after GotConn, so the header still goes out
+				traced.Close = true
+				traced.Header.Set("Connection", "close")
+				delete(limit.counts, info.Conn)
+				limit.limited++
+			}
+		},
+	}
+	traced = request.Clone(httptrace.WithClientTrace(request.Context(), trace))
+	return traced
+}
+
+// Function to record how the server responded on the connection of a finished attempt
+func (limit *connRequestLimit) done(use *connUse, result *requestResult) {
+	if use.conn == nil {
+		return
+	}
+	limit.mu.Lock()
+	defer limit.mu.Unlock()
+	if use.count >= limit.limit {
+		if result.err != nil {
+			limit.failedAtLimit++
+		} else if result.serverClose {
+			limit.serverClosedAtLimit++
+		}
+		return
+	}
+	if result.serverClose {
+		delete(limit.counts, use.conn)
+		limit.serverClosedEarly++
+		limit.maxEarlyRequests = max(limit.maxEarlyRequests, use.count)
+	}
+}
@@ -0,0 +1,66 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+)
+
+// Default header that carries the -correlationId request ID
+const defaultCorrelationHeader = "X-Correlation-Id"
+
+// Errors for a response that did not echo the -verifyEcho request header, and for one that echoed another value,
+// like the ID of a request whose response got crossed with it
+var (
+	errEchoMissing  = errors.New("response echo missing")
+	errEchoMismatch = errors.New("response echo mismatch")
+)
+
+// Request header and the response header that must echo its value for -verifyEcho
+type echoCheck struct {
+	requestHeader  string
+	responseHeader string
+}
+
+// Function to parse a -verifyEcho value like "X-Request-Id=X-Request-Id"
+func parseEchoCheck(value string) (*echoCheck, error) {
+	requestHeader, responseHeader, found := strings.Cut(value, "=")
+	requestHeader, responseHeader = strings.TrimSpace(requestHeader), strings.TrimSpace(responseHeader)
+	if !found || requestHeader == "" || responseHeader == "" {
+		return nil, fmt.Errorf("expected \"requestHeader=responseHeader\"")
+	}
+	return &echoCheck{requestHeader: requestHeader, responseHeader: responseHeader}, nil
+}
+
+// Function to check that the response echoed the request header value.  A request without the header is not
+// checked.
+func (check *echoCheck) verify(request http.Header, response http.Header) error {
+	sent := request.Get(check.requestHeader)
+	if sent == "" {
+		return nil
+	}
+	echoed := response.Get(check.responseHeader)
+	if echoed == "" {
+		return fmt.Errorf("%w: %s is missing", errEchoMissing, check.responseHeader)
+	}
+	if echoed != sent {
+		return fmt.Errorf("%w: %s is \"%s\", sent \"%s\"", errEchoMismatch, check.responseHeader, echoed, sent)
+	}
+	return nil
+}
+
+// Function to generate a random version 4 UUID for a request.  The math/rand/v2 top-level functions use per-thread
+// runtime state, so the request threads do not share a lock.
+func newCorrelationID() string {
+	high, low := rand.Uint64(), rand.Uint64()
+	// Set the version 4 and RFC 4122 variant bits
+	high = high&^0xf000 | 0x4000
+	low = low&^(0xc<<60) | 0x8<<60
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", high>>32, high>>16&0xffff, high&0xffff, low>>48,
+		low&0xffffffffffff)
+}
@@ -0,0 +1,80 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+)
+
+// Dialer used for all the test connections.  Forces the IP version and counts the connections by address family.
+type connDialer struct {
+	dialer net.Dialer
+	// Network passed to the dialer, "tcp4", "tcp6", or "tcp" for either
+	network string
+	// Number of connections opened to IPv4 and IPv6 addresses
+	ipv4Conns atomic.Int64
+	ipv6Conns atomic.Int64
+	// Backends from the -hostsFile that the connections rotate through, empty to dial the request address
+	hosts    []string
+	nextHost atomic.Uint64
+	// Local IP addresses and port range the connections bind in rotation, empty and zero to let the system choose
+	localIPs  []net.IP
+	firstPort int
+	lastPort  int
+	nextLocal atomic.Uint64
+	// Number of DNS lookups made while dialing
+	dnsLookups atomic.Int64
+	// Cache of the host name lookups, nil to resolve the host name on every dial
+	dnsCache *dnsCache
+	// Write the request header lines in a random order for -randomizeHeaders
+	randomizeHeaders bool
+}
+
+// Function to get the dial network for an -ipVersion value.  Returns an empty string for an invalid value.
+func ipNetwork(ipVersion string) string {
+	switch ipVersion {
+	case "4":
+		return "tcp4"
+	case "6":
+		return "tcp6"
+	case "auto":
+		return "tcp"
+	}
+	return ""
+}
+
+// Function to open a connection using the configured IP version.  Matches the http.Transport DialContext signature.
+// With a -hostsFile the connection goes to the next backend instead, and the request keeps its Host header and TLS
+// server name.
+func (d *connDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	if d.network != "" && d.network != "tcp" {
+		network = d.network
+	}
+	if len(d.hosts) > 0 {
+		address = d.backendAddress(address)
+	}
+	ctx = d.countLookups(ctx)
+	var conn net.Conn
+	var err error
+	if d.dnsCache != nil {
+		conn, err = d.dialCached(ctx, network, address)
+	} else {
+		conn, err = d.dialLocal(ctx, network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && tcpAddr.IP.To4() == nil {
+		d.ipv6Conns.Add(1)
+	} else {
+		d.ipv4Conns.Add(1)
+	}
+	if d.randomizeHeaders {
+		return &shuffledConn{Conn: conn}, nil
+	}
+	return conn, nil
+}
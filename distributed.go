@@ -0,0 +1,450 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Time between the -worker progress messages and the -coordinator progress lines
+const workerProgressInterval = time.Second
+
+// Message between the -coordinator and a -worker, sent as one JSON object per line
+type workerMessage struct {
+	// "run" from the coordinator, "progress" and "result" from the worker
+	Type string `json:"type"`
+	// Test arguments and -workerToken of a run message
+	Args  []string `json:"args,omitempty"`
+	Token string   `json:"token,omitempty"`
+	// Completed and failed requests so far of a progress message
+	Requests int `json:"requests,omitempty"`
+	Failed   int `json:"failed,omitempty"`
+	// Summary, response times, and exit code of a result message, or the error when the test did not run
+	Result        *Result   `json:"result,omitempty"`
+	ResponseTimes []float64 `json:"responseTimes,omitempty"`
+	ExitCode      int       `json:"exitCode,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Flags that run a command on the machine of the test, which a worker does not take from the network
+var workerRefusedFlags = []string{"-tokenCommand", "-preRequestCommand", "-validatorCommand"}
+
+// State of a worker connected to the coordinator.  The progress is guarded by the coordinator mutex.
+type coordinatedWorker struct {
+	address  string
+	requests int
+	failed   int
+	result   workerMessage
+	err      error
+}
+
+// Function to wait for the workers, push the test arguments to each with its share of -totalCalls, print their
+// combined progress, and print the merged summary when they all finish.  Returns the exit code, which is the highest
+// exit code of the workers.
+func runCoordinator(address string, numWorkers int, token string, args []string) int {
+	testArgs, jsonOut, totalCalls, err := coordinatorArgs(args)
+	if err == nil {
+		err = checkWorkerArgs(testArgs)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v.\n", err)
+		return 1
+	}
+	// A worker without a call to make would fail its test
+	if totalCalls < numWorkers {
+		fmt.Printf("Error: -totalCalls %d is less than -workers %d.\n", totalCalls, numWorkers)
+		return 1
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		fmt.Printf("Error: Listening for the workers on \"%s\" failed: %v\n", address, err)
+		return 1
+	}
+	defer listener.Close()
+
+	fmt.Printf("Coordinator listening on %s for %d workers.\n", listener.Addr(), numWorkers)
+	conns := make([]net.Conn, 0, numWorkers)
+	for len(conns) < numWorkers {
+		conn, err := listener.Accept()
+		if err != nil {
+			fmt.Printf("Error: Accepting a worker failed: %v\n", err)
+			return 1
+		}
+		conns = append(conns, conn)
+		fmt.Printf("Worker %d connected from %s.\n", len(conns), conn.RemoteAddr())
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	workers := make([]*coordinatedWorker, numWorkers)
+	workerCalls := callsPerThread(totalCalls, numWorkers)
+	for i, conn := range conns {
+		workers[i] = &coordinatedWorker{address: conn.RemoteAddr().String()}
+		workerArgs := append(slices.Clone(testArgs), "-totalCalls", strconv.Itoa(workerCalls[i]))
+		wg.Add(1)
+		go coordinateWorker(&wg, &mu, conn, workers[i], token, workerArgs)
+	}
+
+	// Print the combined progress until all the workers finish
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(workerProgressInterval)
+	for running := true; running; {
+		select {
+		case <-ticker.C:
+			requests, failed := 0, 0
+			mu.Lock()
+			for _, worker := range workers {
+				requests += worker.requests
+				failed += worker.failed
+			}
+			mu.Unlock()
+			fmt.Printf("Progress - Workers: %d - Requests: %d - Failed: %d\n", numWorkers, requests, failed)
+		case <-done:
+			running = false
+		}
+	}
+	ticker.Stop()
+
+	exitCode := 0
+	var results []Result
+	var responseTimes []float64
+	for i, worker := range workers {
+		if worker.err != nil {
+			fmt.Printf("Error: Worker %d at %s failed: %v\n", i+1, worker.address, worker.err)
+			exitCode = 1
+			continue
+		}
+		exitCode = max(exitCode, worker.result.ExitCode)
+		results = append(results, *worker.result.Result)
+		responseTimes = append(responseTimes, worker.result.ResponseTimes...)
+	}
+	if len(results) == 0 {
+		return max(exitCode, 1)
+	}
+
+	merged := mergeResults(results, responseTimes)
+	printWorkerResults(workers, &merged)
+	if jsonOut != "" {
+		if err := writeJSON(jsonOut, &merged); err != nil {
+			fmt.Printf("Error: Writing the JSON summary \"%s\" failed: %v\n", jsonOut, err)
+		}
+	}
+	return exitCode
+}
+
+// Function to split the coordinator arguments into the worker test arguments, without the coordinator flags and
+// -totalCalls, and the coordinator -jsonOut file and total calls
+func coordinatorArgs(args []string) ([]string, string, int, error) {
+	var testArgs []string
+	jsonOut, totalCalls := "", 10000
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-coordinator", "-workers", "-workerToken", "-jsonOut", "-totalCalls":
+			if i+1 >= len(args) {
+				return nil, "", 0, fmt.Errorf("missing value for %s", args[i])
+			}
+			i++
+			if args[i-1] == "-jsonOut" {
+				jsonOut = args[i]
+			} else if args[i-1] == "-totalCalls" {
+				calls, err := strconv.Atoi(args[i])
+				if err != nil || calls <= 0 {
+					return nil, "", 0, fmt.Errorf("\"%s\" is not a valid integer", args[i])
+				}
+				totalCalls = calls
+			}
+		default:
+			testArgs = append(testArgs, args[i])
+		}
+	}
+	return testArgs, jsonOut, totalCalls, nil
+}
+
+// Function to check that the test arguments have none of the flags a worker refuses
+func checkWorkerArgs(args []string) error {
+	for _, arg := range args {
+		if slices.Contains(workerRefusedFlags, arg) {
+			return fmt.Errorf("a worker does not run the %s of a coordinator", arg)
+		}
+	}
+	return nil
+}
+
+// Function to send the test arguments to a worker and collect its progress and result messages
+func coordinateWorker(wg *sync.WaitGroup, mu *sync.Mutex, conn net.Conn, worker *coordinatedWorker, token string,
+	args []string) {
+	defer wg.Done()
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(workerMessage{Type: "run", Args: args, Token: token}); err != nil {
+		worker.err = err
+		return
+	}
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+	for {
+		var message workerMessage
+		if err := decoder.Decode(&message); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("the connection closed before the result")
+			}
+			worker.err = err
+			return
+		}
+		switch message.Type {
+		case "progress":
+			mu.Lock()
+			worker.requests, worker.failed = message.Requests, message.Failed
+			mu.Unlock()
+		case "result":
+			if message.Error != "" {
+				worker.err = errors.New(message.Error)
+			} else if message.Result == nil {
+				worker.err = errors.New("the result has no summary")
+			}
+			worker.result = message
+			return
+		}
+	}
+}
+
+// Function to connect to the coordinator, run the test it sends, report the progress while the test runs, and send
+// the summary and response times.  The test runs as a separate process with the received arguments, like the
+// -compareKeepAlive phases.  A test without the worker token, or with a flag that runs a command, is refused.  Returns
+// the exit code.
+func runWorker(address string, token string) int {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		fmt.Printf("Error: Connecting to the coordinator \"%s\" failed: %v\n", address, err)
+		return 1
+	}
+	defer conn.Close()
+
+	var run workerMessage
+	if err := json.NewDecoder(conn).Decode(&run); err != nil || run.Type != "run" {
+		fmt.Printf("Error: Receiving the test from the coordinator failed: %v\n", err)
+		return 1
+	}
+	encoder := json.NewEncoder(conn)
+	result := workerMessage{Type: "result"}
+	if subtle.ConstantTimeCompare([]byte(run.Token), []byte(token)) != 1 {
+		err = errors.New("the test does not have the -workerToken of the worker")
+	} else if err = checkWorkerArgs(run.Args); err == nil {
+		result.Result, result.ResponseTimes, result.ExitCode, err = runWorkerTest(run.Args,
+			func(requests, failed int) {
+				_ = encoder.Encode(workerMessage{Type: "progress", Requests: requests, Failed: failed})
+			})
+	}
+	if err != nil {
+		fmt.Printf("Error: Running the test failed: %v\n", err)
+		result.Error = err.Error()
+		result.ExitCode = 1
+	}
+	if err := encoder.Encode(result); err != nil {
+		fmt.Printf("Error: Sending the result to the coordinator failed: %v\n", err)
+		return 1
+	}
+	return result.ExitCode
+}
+
+// Function to run the test process and follow its per-request output for the progress.  Returns the summary, the
+// response times of the requests that were not cancelled, and the exit code of the test.
+func runWorkerTest(args []string, progress func(requests int, failed int)) (*Result, []float64, int, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	tempDir, err := os.MkdirTemp("", "api-tester-worker")
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	jsonFile, jsonlFile := filepath.Join(tempDir, "summary.json"), filepath.Join(tempDir, "requests.jsonl")
+	cmd := exec.Command(executable, append(slices.Clone(args), "-jsonOut", jsonFile, "-jsonlOut", jsonlFile)...)
+	cmd.Env = childEnv()
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, nil, 0, err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	follower := &jsonlFollower{fileName: jsonlFile}
+	ticker := time.NewTicker(workerProgressInterval)
+	defer ticker.Stop()
+	var waitErr error
+	for running := true; running; {
+		select {
+		case <-ticker.C:
+			follower.read()
+			progress(len(follower.responseTimes), follower.failed)
+		case waitErr = <-exited:
+			running = false
+		}
+	}
+	follower.read()
+
+	exitCode := 0
+	if waitErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(waitErr, &exitErr) {
+			return nil, nil, 0, waitErr
+		}
+		exitCode = exitErr.ExitCode()
+	}
+	data, err := os.ReadFile(jsonFile)
+	if err != nil {
+		return nil, nil, exitCode, fmt.Errorf("the test wrote no summary: %w", err)
+	}
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, nil, exitCode, err
+	}
+	return &result, follower.responseTimes, exitCode, nil
+}
+
+// Reader of the complete lines added to a -jsonlOut file since the last read
+type jsonlFollower struct {
+	fileName      string
+	offset        int64
+	responseTimes []float64
+	failed        int
+}
+
+// Function to read the new complete lines.  A partly written last line is read the next time.
+func (follower *jsonlFollower) read() {
+	file, err := os.Open(follower.fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	if _, err := file.Seek(follower.offset, io.SeekStart); err != nil {
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return
+	}
+	end := strings.LastIndexByte(string(data), '\n')
+	if end < 0 {
+		return
+	}
+	follower.offset += int64(end + 1)
+	for _, line := range strings.Split(string(data[:end]), "\n") {
+		var result requestResult
+		if json.Unmarshal([]byte(line), &result) != nil || result.Cancelled {
+			continue
+		}
+		follower.responseTimes = append(follower.responseTimes, result.ResponseTime)
+		if result.Error != "" {
+			follower.failed++
+		}
+	}
+}
+
+// Function to merge the worker summaries.  The counts add up and the percentiles come from the response times of
+// all the workers.
+func mergeResults(results []Result, responseTimes []float64) Result {
+	merged := Result{Label: results[0].Label, URL: results[0].URL, StatusCodes: make(map[string]*LatencySummary)}
+	totalResponseTime, totalResponseSize := 0.0, 0.0
+	for i, result := range results {
+		merged.Threads += result.Threads
+		merged.TotalRequests += result.TotalRequests
+		merged.TotalTime = max(merged.TotalTime, result.TotalTime)
+		merged.RequestsPerSecond += result.RequestsPerSecond
+		merged.FailedRequests += result.FailedRequests
+		merged.ConnectionFailures += result.ConnectionFailures
+		merged.FailedAfterConnecting += result.FailedAfterConnecting
+		merged.SatisfiedRequests += result.SatisfiedRequests
+		merged.CancelledRequests += result.CancelledRequests
+		merged.ConnectionsOpened += result.ConnectionsOpened
+		totalResponseTime += result.AverageResponseTime * float64(result.TotalRequests)
+		totalResponseSize += result.AverageResponseSize * float64(result.TotalRequests)
+		if i == 0 || result.MinResponseSize < merged.MinResponseSize {
+			merged.MinResponseSize = result.MinResponseSize
+		}
+		merged.MaxResponseSize = max(merged.MaxResponseSize, result.MaxResponseSize)
+
+		for code, summary := range result.StatusCodes {
+			mergedSummary, ok := merged.StatusCodes[code]
+			if !ok {
+				mergedSummary = &LatencySummary{MinResponseTime: summary.MinResponseTime}
+				merged.StatusCodes[code] = mergedSummary
+			}
+			mergedSummary.totalResponseTime += summary.AverageResponseTime * float64(summary.Count)
+			mergedSummary.Count += summary.Count
+			mergedSummary.AverageResponseTime = mergedSummary.totalResponseTime / float64(mergedSummary.Count)
+			mergedSummary.MinResponseTime = min(mergedSummary.MinResponseTime, summary.MinResponseTime)
+			mergedSummary.MaxResponseTime = max(mergedSummary.MaxResponseTime, summary.MaxResponseTime)
+		}
+	}
+	if merged.TotalRequests > 0 {
+		merged.AverageResponseTime = totalResponseTime / float64(merged.TotalRequests)
+		merged.AverageResponseSize = totalResponseSize / float64(merged.TotalRequests)
+	}
+
+	slices.Sort(responseTimes)
+	merged.P50ResponseTime = percentile(responseTimes, 50)
+	merged.P90ResponseTime = percentile(responseTimes, 90)
+	merged.P95ResponseTime = percentile(responseTimes, 95)
+	merged.P99ResponseTime = percentile(responseTimes, 99)
+	for _, p := range []float64{90, 95, 99} {
+		if len(responseTimes) > 0 && !percentileSupported(len(responseTimes), p) {
+			merged.UnreliablePercentiles = append(merged.UnreliablePercentiles, fmt.Sprintf("p%g", p))
+		}
+	}
+	return merged
+}
+
+// Function to print a line for each worker and the merged summary
+func printWorkerResults(workers []*coordinatedWorker, merged *Result) {
+	fmt.Println("==================== Workers ====================")
+	for i, worker := range workers {
+		if worker.err != nil {
+			fmt.Printf("Worker %d %-21s - Failed: %v\n", i+1, worker.address, worker.err)
+			continue
+		}
+		result := worker.result.Result
+		fmt.Printf("Worker %d %-21s - Requests: %d - Failed: %d - Requests per second: %.2f - p99: %.2f ms\n", i+1,
+			worker.address, result.TotalRequests, result.FailedRequests, result.RequestsPerSecond,
+			result.P99ResponseTime)
+	}
+	fmt.Println("==================== Combined ====================")
+	fmt.Printf("Total thread count: %d\n", merged.Threads)
+	fmt.Printf("Total test time: %.2f s\n", merged.TotalTime)
+	fmt.Printf("Total requests: %d\n", merged.TotalRequests)
+	fmt.Printf("Average response time: %.2f ms\n", merged.AverageResponseTime)
+	fmt.Printf("Percentile response times: p50 %.2f ms - p90 %.2f ms - p95 %.2f ms - p99 %.2f ms\n",
+		merged.P50ResponseTime, merged.P90ResponseTime, merged.P95ResponseTime, merged.P99ResponseTime)
+	if len(merged.UnreliablePercentiles) > 0 {
+		fmt.Printf("Note: %d samples are too few for a reliable %s.\n", merged.TotalRequests,
+			strings.Join(merged.UnreliablePercentiles, ", "))
+	}
+	fmt.Printf("Requests per second: %.2f\n", merged.RequestsPerSecond)
+	fmt.Printf("Failed requests: %d\n", merged.FailedRequests)
+	if merged.FailedRequests > 0 {
+		fmt.Printf("Connection failures: %d - Failed after connecting: %d\n", merged.ConnectionFailures,
+			merged.FailedAfterConnecting)
+	}
+	printStatusCodes(merged)
+	fmt.Printf("Connections opened: %d\n", merged.ConnectionsOpened)
+}
@@ -0,0 +1,76 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+)
+
+// A worker refuses a test without its token or with a flag that runs a command, and does not start the test
+func TestWorkerRefusesTests(t *testing.T) {
+	cases := []struct {
+		name     string
+		run      workerMessage
+		expected string
+	}{
+		{"no token", workerMessage{Type: "run", Args: []string{"http://127.0.0.1:1"}}, "-workerToken"},
+		{"wrong token", workerMessage{Type: "run", Args: []string{"http://127.0.0.1:1"}, Token: "guess"},
+			"-workerToken"},
+		{"command flag", workerMessage{Type: "run", Token: "secret",
+			Args: []string{"http://127.0.0.1:1", "-validatorCommand", "touch /tmp/x"}}, "-validatorCommand"},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			exitCode := make(chan int, 1)
+			go func() { exitCode <- runWorker(listener.Addr().String(), "secret") }()
+
+			conn, err := listener.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if err := json.NewEncoder(conn).Encode(test.run); err != nil {
+				t.Fatal(err)
+			}
+			var result workerMessage
+			if err := json.NewDecoder(conn).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Type != "result" || result.Result != nil || !strings.Contains(result.Error, test.expected) {
+				t.Errorf("Expected a refusal with \"%s\", got %+v", test.expected, result)
+			}
+			if code := <-exitCode; code != 1 {
+				t.Errorf("Expected exit code 1, got %d", code)
+			}
+		})
+	}
+}
+
+// The coordinator and the worker need a token, and the coordinator refuses the command flags before it listens
+func TestCoordinatorArguments(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-worker", "127.0.0.1:1"}, "Error: -worker and -coordinator need a shared -workerToken."},
+		{[]string{"http://x", "-coordinator", "127.0.0.1:0"},
+			"Error: -worker and -coordinator need a shared -workerToken."},
+		{[]string{"http://x", "-coordinator", "127.0.0.1:0", "-workerToken", "secret", "-tokenCommand", "echo x"},
+			"Error: a worker does not run the -tokenCommand of a coordinator."},
+	}
+	for _, test := range cases {
+		output, code := runMain(t, nil, test.args...)
+		if code != 1 || !strings.Contains(output, test.expected) || strings.Contains(output, "listening") {
+			t.Errorf("Args %q exited with %d and output:\n%s", test.args, code, output)
+		}
+	}
+}
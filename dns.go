@@ -0,0 +1,94 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// In-process cache of the -dnsCache host name lookups
+type dnsCache struct {
+	// How long a lookup is reused before the host name is resolved again
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+	// Number of dials that were resolved from the cache
+	hits atomic.Int64
+	// Number of dials that needed a host name
+	dials atomic.Int64
+}
+
+// Cached addresses of a host name
+type dnsEntry struct {
+	addresses []net.IPAddr
+	expires   time.Time
+}
+
+// Function to create a DNS cache that keeps the lookups for the TTL
+func newDNSCache(ttl time.Duration) *dnsCache {
+	return &dnsCache{ttl: ttl, entries: make(map[string]dnsEntry)}
+}
+
+// Function to get the addresses of a host name from the cache, or to look them up and cache them
+func (cache *dnsCache) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	cache.dials.Add(1)
+	cache.mu.Lock()
+	entry, ok := cache.entries[host]
+	cache.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		cache.hits.Add(1)
+		return entry.addresses, nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	cache.mu.Lock()
+	cache.entries[host] = dnsEntry{addresses: addresses, expires: time.Now().Add(cache.ttl)}
+	cache.mu.Unlock()
+	return addresses, nil
+}
+
+// Function to count the DNS lookups made while dialing
+func (d *connDialer) countLookups(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { d.dnsLookups.Add(1) },
+	})
+}
+
+// Function to dial a host name through the DNS cache, trying each cached address of the IP version in turn
+func (d *connDialer) dialCached(ctx context.Context, network string, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialLocal(ctx, network, address)
+	}
+	addresses, err := d.dnsCache.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialErr error
+	for _, ipAddr := range addresses {
+		isIPv4 := ipAddr.IP.To4() != nil
+		if (network == "tcp4" && !isIPv4) || (network == "tcp6" && isIPv4) {
+			continue
+		}
+		conn, err := d.dialLocal(ctx, network, net.JoinHostPort(ipAddr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		dialErr = err
+	}
+	if dialErr == nil {
+		dialErr = fmt.Errorf("no %s address found for \"%s\"", network, host)
+	}
+	return nil, dialErr
+}
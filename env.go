@@ -0,0 +1,123 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Environment variable for the server URL
+const envURL = "APITESTER_URL"
+
+// Environment variables for the flags and whether the flag takes a value.  The command line flags are parsed after
+// them, so a flag on the command line overrides its environment variable.
+var envFlags = []struct {
+	name     string
+	flag     string
+	hasValue bool
+}{
+	{"APITESTER_THREADS", "-numThreads", true},
+	{"APITESTER_TOTAL_CALLS", "-totalCalls", true},
+	{"APITESTER_SLEEP_TIME", "-sleepTime", true},
+	{"APITESTER_REQUEST_TIMEOUT", "-requestTimeOut", true},
+	{"APITESTER_CONNECT_TIMEOUT", "-connectTimeOut", true},
+	{"APITESTER_METHOD", "-method", true},
+	{"APITESTER_TARGET", "-target", true},
+	{"APITESTER_RAMP_UP", "-rampUp", true},
+	{"APITESTER_WARMUP", "-warmup", true},
+	{"APITESTER_RETRIES", "-retries", true},
+	{"APITESTER_MAX_ERRORS", "-maxErrors", true},
+	{"APITESTER_SLO", "-slo", true},
+	{"APITESTER_OAUTH_TOKEN_URL", "-oauthTokenURL", true},
+	{"APITESTER_OAUTH_CLIENT_ID", "-oauthClientID", true},
+	{"APITESTER_OAUTH_CLIENT_SECRET", "-oauthClientSecret", true},
+	{"APITESTER_OAUTH_SCOPES", "-oauthScopes", true},
+	{"APITESTER_TOKEN_COMMAND", "-tokenCommand", true},
+	{"APITESTER_JSON_OUT", "-jsonOut", true},
+	{"APITESTER_JSONL_OUT", "-jsonlOut", true},
+	{"APITESTER_CSV_OUT", "-csvOut", true},
+	{"APITESTER_METRICS_FILE", "-metricsFile", true},
+	{"APITESTER_INFLUX_URL", "-influxUrl", true},
+	{"APITESTER_INFLUX_TOKEN", "-influxToken", true},
+	{"APITESTER_REUSE_CONNECTS", "-reuseConnects", false},
+	{"APITESTER_VERBOSE", "-verbose", false},
+	{"APITESTER_STOP_ON_ERROR", "-stopOnFirstError", false},
+}
+
+// Function to add the flags set by the APITESTER_ environment variables to the command line arguments.  They go
+// after the URL and before the command line flags.  The APITESTER_URL is used when there is no URL argument.  A
+// variable is skipped when its flag is on the command line, so the command line overrides it, and an -slo from the
+// command line replaces the APITESTER_SLO instead of adding to it.  Boolean flags are set by a true value like "1"
+// or "true", and the command line turns them on or off with "-flag=true" or "-flag=false".
+func argsWithEnv(args []string) ([]string, error) {
+	rest := slices.Clone(args[1:])
+	joined := []string{args[0]}
+	if len(rest) > 0 && strings.HasPrefix(rest[0], "http") {
+		joined = append(joined, rest[0])
+		rest = rest[1:]
+	} else if url := os.Getenv(envURL); url != "" {
+		joined = append(joined, url)
+	}
+
+	var envArgs []string
+	for _, env := range envFlags {
+		onCommandLine := false
+		for i := 0; i < len(rest); i++ {
+			if rest[i] == env.flag {
+				onCommandLine = true
+			} else if value, found := strings.CutPrefix(rest[i], env.flag+"="); found && !env.hasValue {
+				set, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("\"%s\" is not a valid boolean for %s", value, env.flag)
+				}
+				onCommandLine = true
+				if set {
+					rest[i] = env.flag
+				} else {
+					rest = slices.Delete(rest, i, i+1)
+					i--
+				}
+			}
+		}
+		value, ok := os.LookupEnv(env.name)
+		if onCommandLine || !ok || value == "" {
+			continue
+		}
+		if env.hasValue {
+			envArgs = append(envArgs, env.flag, value)
+			continue
+		}
+		set, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("\"%s\" is not a valid boolean for %s", value, env.name)
+		}
+		if set {
+			envArgs = append(envArgs, env.flag)
+		}
+	}
+	joined = append(joined, envArgs...)
+	return append(joined, rest...), nil
+}
+
+// Function to get the environment of a child process of the tester, like a -compareKeepAlive phase or a -worker
+// test, without the APITESTER_ flag variables.  The child gets the flags in its arguments already, so they would be
+// applied twice.
+func childEnv() []string {
+	names := map[string]bool{envURL: true}
+	for _, env := range envFlags {
+		names[env.name] = true
+	}
+	var environ []string
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if !names[name] {
+			environ = append(environ, variable)
+		}
+	}
+	return environ
+}
@@ -0,0 +1,74 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// The environment flags go after the URL, and the same flag on the command line overrides them
+func TestArgsWithEnv(t *testing.T) {
+	cases := []struct {
+		name     string
+		env      map[string]string
+		args     []string
+		expected []string
+	}{
+		{"environment flags", map[string]string{"APITESTER_THREADS": "4", "APITESTER_VERBOSE": "true"},
+			[]string{"http://x", "-totalCalls", "10"},
+			[]string{"http://x", "-numThreads", "4", "-verbose", "-totalCalls", "10"}},
+		{"environment URL", map[string]string{"APITESTER_URL": "http://x"}, []string{"-numThreads", "2"},
+			[]string{"http://x", "-numThreads", "2"}},
+		{"command line value", map[string]string{"APITESTER_THREADS": "4"}, []string{"http://x", "-numThreads", "2"},
+			[]string{"http://x", "-numThreads", "2"}},
+		{"command line SLO replaces", map[string]string{"APITESTER_SLO": "p99<500ms"},
+			[]string{"http://x", "-slo", "avg<100ms"}, []string{"http://x", "-slo", "avg<100ms"}},
+		{"boolean turned off", map[string]string{"APITESTER_REUSE_CONNECTS": "1"},
+			[]string{"http://x", "-reuseConnects=false"}, []string{"http://x"}},
+		{"boolean turned on", nil, []string{"http://x", "-reuseConnects=true"}, []string{"http://x", "-reuseConnects"}},
+		{"boolean false variable", map[string]string{"APITESTER_VERBOSE": "0"}, []string{"http://x"},
+			[]string{"http://x"}},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			for _, env := range envFlags {
+				t.Setenv(env.name, "")
+			}
+			t.Setenv(envURL, "")
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			args, err := argsWithEnv(append([]string{"api-tester"}, test.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := append([]string{"api-tester"}, test.expected...); !slices.Equal(args, expected) {
+				t.Errorf("Expected %q, got %q", expected, args)
+			}
+		})
+	}
+
+	t.Setenv("APITESTER_VERBOSE", "")
+	if _, err := argsWithEnv([]string{"api-tester", "http://x", "-verbose=maybe"}); err == nil {
+		t.Error("Expected an error for -verbose=maybe")
+	}
+}
+
+// The child processes do not get the APITESTER_ flag variables, which are in their arguments already
+func TestChildEnv(t *testing.T) {
+	t.Setenv("APITESTER_SLO", "p99<500ms")
+	t.Setenv("APITESTER_URL", "http://x")
+	t.Setenv("API_TESTER_OTHER", "kept")
+	environ := childEnv()
+	for _, variable := range environ {
+		if strings.HasPrefix(variable, "APITESTER_SLO=") || strings.HasPrefix(variable, "APITESTER_URL=") {
+			t.Errorf("The child environment has %s", variable)
+		}
+	}
+	if !slices.Contains(environ, "API_TESTER_OTHER=kept") {
+		t.Error("The child environment is missing the other variables")
+	}
+}
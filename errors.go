@@ -0,0 +1,70 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Volatile parts of error messages that would split the same failure into many groups
+var (
+	errorPortPattern  = regexp.MustCompile(`(\d+\.\d+\.\d+\.\d+|\]|localhost):\d+`)
+	errorQueryPattern = regexp.MustCompile(`\?[^"\s]*`)
+	// Request IDs, like the -verifyEcho values
+	errorUUIDPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+)
+
+// Failed requests with the same normalized error message
+type errorGroup struct {
+	count int
+	// First error message of the group as it was reported
+	example string
+}
+
+// Function to strip the port numbers, query strings, and UUIDs from an error message so the same failure groups
+// together
+func normalizeError(message string) string {
+	message = errorPortPattern.ReplaceAllString(message, "$1:PORT")
+	message = errorUUIDPattern.ReplaceAllString(message, "UUID")
+	return errorQueryPattern.ReplaceAllString(message, "?QUERY")
+}
+
+// Function to add a failed request to the error group for its normalized message.  The caller must hold the output
+// mutex.
+func (stats *testStats) addErrorGroup(message string) {
+	if stats.errorGroups == nil {
+		stats.errorGroups = make(map[string]*errorGroup)
+	}
+	key := normalizeError(message)
+	group, ok := stats.errorGroups[key]
+	if !ok {
+		group = &errorGroup{example: message}
+		stats.errorGroups[key] = group
+	}
+	group.count++
+}
+
+// Function to print the unique error messages with their counts and an example, most frequent first
+func printErrorGroups(groups map[string]*errorGroup) {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if groups[keys[a]].count != groups[keys[b]].count {
+			return groups[keys[a]].count > groups[keys[b]].count
+		}
+		return keys[a] < keys[b]
+	})
+
+	if len(keys) > 0 {
+		fmt.Println("Errors:")
+	}
+	for _, key := range keys {
+		fmt.Printf("  %6d x %s\n", groups[key].count, key)
+		fmt.Printf("           Example: %s\n", groups[key].example)
+	}
+}
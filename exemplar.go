@@ -0,0 +1,71 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"time"
+)
+
+// Individual request kept for the -exemplarsOut file, written as a JSON line
+type exemplar struct {
+	Time          time.Time `json:"time"`
+	ResponseTime  float64   `json:"responseTimeMs"`
+	StatusCode    int       `json:"statusCode"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	// -label run label
+	Label string `json:"label,omitempty"`
+}
+
+// Uniform random sample of the recorded requests with bounded memory (reservoir sampling).  Guarded by the output
+// mutex.
+type exemplarReservoir struct {
+	size int
+	// -label run label added to every sample
+	label   string
+	seen    int
+	samples []exemplar
+}
+
+// Function to offer a request to the reservoir
+func (reservoir *exemplarReservoir) add(result *requestResult) {
+	reservoir.seen++
+	sample := exemplar{Time: result.startTime, ResponseTime: result.ResponseTime, StatusCode: result.StatusCode,
+		CorrelationID: result.CorrelationID, Label: reservoir.label}
+	if len(reservoir.samples) < reservoir.size {
+		reservoir.samples = append(reservoir.samples, sample)
+	} else if slot := rand.IntN(reservoir.seen); slot < reservoir.size {
+		reservoir.samples[slot] = sample
+	}
+}
+
+// Function to write the sampled requests in time order as JSON Lines, one object per request with its start time,
+// response time, status, and correlation ID, to attach to the traces of the requests
+func (reservoir *exemplarReservoir) write(fileName string) error {
+	sort.Slice(reservoir.samples, func(a, b int) bool {
+		return reservoir.samples[a].Time.Before(reservoir.samples[b].Time)
+	})
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for i := range reservoir.samples {
+		if err := encoder.Encode(&reservoir.samples[i]); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,55 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Time the transport waits for a "100 Continue" before it sends the -expect100 request body anyway
+const expectContinueTimeout = 1000 * time.Millisecond
+
+// Expect-continue handshake of a single -expect100 attempt.  Has its own mutex because the write hooks run on the
+// transport goroutines.
+type continueTrace struct {
+	mu           sync.Mutex
+	wroteHeaders time.Time
+	// Time from the request headers to the "100 Continue", set when the server sent one
+	wait   time.Duration
+	got100 bool
+}
+
+// Function to send the request with "Expect: 100-continue" and time the wait for the server to ask for the body
+func (trace *continueTrace) withTrace(request *http.Request) *http.Request {
+	request.Header.Set("Expect", "100-continue")
+	clientTrace := &httptrace.ClientTrace{
+		WroteHeaders: func() {
+			trace.mu.Lock()
+			trace.wroteHeaders = time.Now()
+			trace.mu.Unlock()
+		},
+		Got100Continue: func() {
+			trace.mu.Lock()
+			trace.wait = time.Since(trace.wroteHeaders)
+			trace.got100 = true
+			trace.mu.Unlock()
+		},
+	}
+	return request.WithContext(httptrace.WithClientTrace(request.Context(), clientTrace))
+}
+
+// Function to get whether the server sent a "100 Continue" before the body and the wait for it in milliseconds
+func (trace *continueTrace) finish() (bool, float64) {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	return trace.got100, milliseconds(trace.wait)
+}
+
+// Function to check if a request has a body for the server to accept or refuse
+func hasRequestBody(request *http.Request) bool {
+	return request.Body != nil && request.Body != http.NoBody
+}
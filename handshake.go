@@ -0,0 +1,55 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+// TLS handshake statistics over all the connections.  Updated by the request threads without the output mutex.
+type handshakeStats struct {
+	count       atomic.Int64
+	resumed     atomic.Int64
+	totalMicros atomic.Int64
+}
+
+// Function to add a completed TLS handshake
+func (handshakes *handshakeStats) add(duration time.Duration, state tls.ConnectionState) {
+	handshakes.count.Add(1)
+	handshakes.totalMicros.Add(duration.Microseconds())
+	if state.DidResume {
+		handshakes.resumed.Add(1)
+	}
+}
+
+// Function to get the average handshake time in milliseconds.  Returns zero when there were no handshakes.
+func (handshakes *handshakeStats) average() float64 {
+	count := handshakes.count.Load()
+	if count == 0 {
+		return 0
+	}
+	return float64(handshakes.totalMicros.Load()) / 1000 / float64(count)
+}
+
+// Function to add the TLS handshakes of the connections the request opens to the statistics.  Sets the remote
+// address of the connection used by each attempt in backend if it is not nil.
+func withHandshakeTrace(request *http.Request, handshakes *handshakeStats, backend *string) *http.Request {
+	var startTime time.Time
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeStart: func() { startTime = time.Now() },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				handshakes.add(time.Since(startTime), state)
+			}
+		},
+	}
+	if backend != nil {
+		trace.GotConn = func(info httptrace.GotConnInfo) { *backend = info.Conn.RemoteAddr().String() }
+	}
+	return request.WithContext(httptrace.WithClientTrace(request.Context(), trace))
+}
@@ -0,0 +1,144 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Parts of a HAR (HTTP Archive) capture used to replay the requests
+type harArchive struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime time.Time `json:"startedDateTime"`
+			Request         struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					Text string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// Request from a HAR capture and its start time relative to the first request
+type harRequest struct {
+	request stdinRequest
+	offset  time.Duration
+}
+
+// Function to read the requests of a HAR file in start time order
+func loadHARFile(fileName string) ([]harRequest, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var archive harArchive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, err
+	}
+	entries := archive.Log.Entries
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no requests in the file")
+	}
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].StartedDateTime.Before(entries[b].StartedDateTime)
+	})
+
+	requests := make([]harRequest, 0, len(entries))
+	for _, entry := range entries {
+		req := stdinRequest{Method: entry.Request.Method, URL: entry.Request.URL, Headers: make(map[string]string)}
+		for _, header := range entry.Request.Headers {
+			// Skip the HTTP/2 pseudo-headers and the headers the client sets for the connection and body
+			name := strings.ToLower(header.Name)
+			if strings.HasPrefix(name, ":") || name == "content-length" || name == "host" || name == "connection" {
+				continue
+			}
+			req.Headers[header.Name] = header.Value
+		}
+		if entry.Request.PostData != nil {
+			req.Body = entry.Request.PostData.Text
+		}
+		offset := entry.StartedDateTime.Sub(entries[0].StartedDateTime)
+		requests = append(requests, harRequest{request: req, offset: offset})
+	}
+	return requests, nil
+}
+
+// How closely the -replayTiming dispatch followed the captured schedule
+type replaySchedule struct {
+	// Number of times the -replayLoop list was started
+	loops      int
+	dispatched int
+	totalLate  time.Duration
+	maxLate    time.Duration
+}
+
+// Function to feed the HAR requests to the workers.  With replayTiming the requests are dispatched at their capture
+// offsets multiplied by timeScale, and the lateness of each hand-off to a worker is measured.  A loop limit above
+// zero replays the list over and over until that many requests are sent, each loop starting on the schedule after
+// the last request of the previous one.  Closes the channel and the done channel when finished.
+func replayHARRequests(ctx context.Context, requests []harRequest, replayTiming bool, timeScale float64,
+	loopLimit int, out chan<- stdinRequest, schedule *replaySchedule, done chan<- struct{}) {
+	defer close(done)
+	defer close(out)
+
+	startTime := time.Now()
+	for sent := 0; ; {
+		schedule.loops++
+		if !replayList(ctx, requests, replayTiming, timeScale, startTime, loopLimit-sent, out, schedule) {
+			return
+		}
+		sent += len(requests)
+		if loopLimit <= 0 || sent >= loopLimit {
+			return
+		}
+		startTime = startTime.Add(time.Duration(float64(requests[len(requests)-1].offset) * timeScale))
+	}
+}
+
+// Function to feed one pass of the HAR requests to the workers, at most limit requests when it is above zero.
+// Returns false when the test was stopped or the limit was reached.
+func replayList(ctx context.Context, requests []harRequest, replayTiming bool, timeScale float64,
+	startTime time.Time, limit int, out chan<- stdinRequest, schedule *replaySchedule) bool {
+	for i, req := range requests {
+		if limit > 0 && i >= limit {
+			return false
+		}
+		var due time.Time
+		if replayTiming {
+			due = startTime.Add(time.Duration(float64(req.offset) * timeScale))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return false
+				}
+			}
+		}
+		select {
+		case out <- req.request:
+		case <-ctx.Done():
+			return false
+		}
+		if replayTiming {
+			late := max(0, time.Since(due))
+			schedule.dispatched++
+			schedule.totalLate += late
+			schedule.maxLate = max(schedule.maxLate, late)
+		}
+	}
+	return true
+}
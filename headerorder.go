@@ -0,0 +1,87 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Connection that writes the header lines of each request in a random order for -randomizeHeaders.  The http.Header
+// map is always written sorted by name, so the request head is rearranged on its way to the connection instead.
+// Has its own mutex because the transport reads and writes the connection on different goroutines.
+type shuffledConn struct {
+	net.Conn
+	mu sync.Mutex
+	// Start of the request head written so far, until its blank line is written
+	head []byte
+	// The head of the current request was written and the rest is its body
+	inBody bool
+	// The first write was a TLS handshake, so the requests are encrypted and pass through as they are
+	encrypted bool
+	started   bool
+}
+
+// End of the HTTP request head
+var headEnd = []byte("\r\n\r\n")
+
+// Function to write to the connection, holding back each request head until it is complete and then writing it with
+// its header lines shuffled
+func (conn *shuffledConn) Write(data []byte) (int, error) {
+	conn.mu.Lock()
+	if !conn.started {
+		conn.started = true
+		// A TLS record starts with the handshake content type
+		conn.encrypted = len(data) > 0 && data[0] == 0x16
+	}
+	if conn.encrypted || conn.inBody {
+		conn.mu.Unlock()
+		return conn.Conn.Write(data)
+	}
+	conn.head = append(conn.head, data...)
+	end := bytes.Index(conn.head, headEnd)
+	if end < 0 {
+		conn.mu.Unlock()
+		return len(data), nil
+	}
+	out := shuffleHeaderLines(conn.head[:end])
+	out = append(out, headEnd...)
+	out = append(out, conn.head[end+len(headEnd):]...)
+	conn.head = nil
+	conn.inBody = true
+	conn.mu.Unlock()
+
+	if _, err := conn.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Function to read from the connection.  A response means the request was written, so the next write starts the
+// head of the next request.
+func (conn *shuffledConn) Read(data []byte) (int, error) {
+	n, err := conn.Conn.Read(data)
+	if n > 0 {
+		conn.mu.Lock()
+		conn.inBody = false
+		conn.mu.Unlock()
+	}
+	return n, err
+}
+
+// Function to shuffle the header lines of a request head without its blank line, keeping the request line first
+func shuffleHeaderLines(head []byte) []byte {
+	lines := bytes.Split(head, []byte("\r\n"))
+	headers := lines[1:]
+	rand.Shuffle(len(headers), func(i int, j int) { headers[i], headers[j] = headers[j], headers[i] })
+	return bytes.Join(lines, []byte("\r\n"))
+}
+
+// Function to check if a URL is https, whose requests pass through a shuffled connection encrypted
+func isHTTPS(url string) bool {
+	return strings.HasPrefix(strings.ToLower(url), "https:")
+}
@@ -0,0 +1,153 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Command run before the requests for -preRequestCommand to generate headers and a body, like a signature
+type preRequestHook struct {
+	args    []string
+	timeout time.Duration
+	// How long an output is reused for the next requests, zero to run the command for every request
+	cacheTime time.Duration
+	mu        sync.Mutex
+	cached    *hookOutput
+	cachedAt  time.Time
+	// Number of command runs and their total time, and the requests that used a cached output
+	runs      int
+	runTime   time.Duration
+	cacheHits int
+}
+
+// Headers and body printed by a -preRequestCommand
+type hookOutput struct {
+	headers http.Header
+	// Request body, nil to keep the request body
+	body []byte
+}
+
+// Function to create a pre-request hook for a command line
+func newPreRequestHook(command string, timeout time.Duration, cacheTime time.Duration) (*preRequestHook, error) {
+	args, err := splitQuoted(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return &preRequestHook{args: args, timeout: timeout, cacheTime: cacheTime}, nil
+}
+
+// Function to add the command output to a request, running the command unless its cached output is still fresh
+func (hook *preRequestHook) apply(request *http.Request, threadID int, iteration int) error {
+	output, err := hook.output(request, threadID, iteration)
+	if err != nil {
+		return fmt.Errorf("pre-request command failed: %w", err)
+	}
+	for name, values := range output.headers {
+		request.Header[name] = values
+	}
+	if output.body != nil {
+		setRequestBody(request, output.body)
+	}
+	return nil
+}
+
+// Function to get the command output for a request.  With a cache time the lock is held while the command runs, so
+// the threads wait for a single run instead of all running the command at once.
+func (hook *preRequestHook) output(request *http.Request, threadID int, iteration int) (*hookOutput, error) {
+	if hook.cacheTime > 0 {
+		hook.mu.Lock()
+		defer hook.mu.Unlock()
+		if hook.cached != nil && time.Since(hook.cachedAt) < hook.cacheTime {
+			hook.cacheHits++
+			return hook.cached, nil
+		}
+		output, runTime, err := hook.run(request, threadID, iteration)
+		hook.runs++
+		hook.runTime += runTime
+		if err != nil {
+			return nil, err
+		}
+		hook.cached, hook.cachedAt = output, time.Now()
+		return output, nil
+	}
+
+	output, runTime, err := hook.run(request, threadID, iteration)
+	hook.mu.Lock()
+	hook.runs++
+	hook.runTime += runTime
+	hook.mu.Unlock()
+	return output, err
+}
+
+// Function to run the command with the request details in the environment and parse its output
+func (hook *preRequestHook) run(request *http.Request, threadID int, iteration int) (*hookOutput, time.Duration,
+	error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, hook.args[0], hook.args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"APITESTER_METHOD="+request.Method,
+		"APITESTER_URL="+request.URL.String(),
+		"APITESTER_THREAD="+strconv.Itoa(threadID),
+		"APITESTER_ITERATION="+strconv.Itoa(iteration))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	startTime := time.Now()
+	data, err := cmd.Output()
+	runTime := time.Since(startTime)
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, runTime, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, runTime, err
+	}
+	output, err := parseHookOutput(data)
+	return output, runTime, err
+}
+
+// Function to parse the command output.  The output is "Name: value" header lines, then optionally a blank line
+// and the request body, like an HTTP message.
+func parseHookOutput(data []byte) (*hookOutput, error) {
+	output := &hookOutput{headers: make(http.Header)}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	headers, body, hasBody := strings.Cut(text, "\n\n")
+	if hasBody {
+		output.body = []byte(body)
+	}
+	for _, line := range strings.Split(headers, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("expected \"Name: value\" but found \"%s\"", line)
+		}
+		output.headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return output, nil
+}
+
+// Function to get the number of command runs, their average time in milliseconds, and the cache hits
+func (hook *preRequestHook) summary() (int, float64, int) {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if hook.runs == 0 {
+		return 0, 0, hook.cacheHits
+	}
+	return hook.runs, milliseconds(hook.runTime) / float64(hook.runs), hook.cacheHits
+}
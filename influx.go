@@ -0,0 +1,173 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InfluxDB measurements of the test summary and the -influxInterval snapshots
+const (
+	influxMeasurement         = "api_tester"
+	influxIntervalMeasurement = "api_tester_interval"
+)
+
+// Destination of the InfluxDB line protocol output, a file, a write URL, or both
+type influxWriter struct {
+	fileName string
+	url      string
+	// Token sent as "Authorization: Token", empty to send none
+	token  string
+	client *http.Client
+	// Tag set shared by all the lines, starting with a comma
+	tags string
+}
+
+// Function to create the InfluxDB output.  The lines are tagged with the label, when set, the host name of the tester,
+// and the test URL.
+func newInfluxWriter(fileName string, url string, token string, label string, testURL string,
+	timeout time.Duration) *influxWriter {
+	host, _ := os.Hostname()
+	tags := map[string]string{"label": label, "host": host, "url": testURL}
+	names := make([]string, 0, len(tags))
+	for name, value := range tags {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	// InfluxDB writes are fastest with the tags sorted by name
+	sort.Strings(names)
+	var tagSet strings.Builder
+	for _, name := range names {
+		tagSet.WriteString("," + name + "=" + escapeInfluxTag(tags[name]))
+	}
+	writer := &influxWriter{fileName: fileName, url: url, token: token, tags: tagSet.String()}
+	if url != "" {
+		writer.client = &http.Client{Timeout: timeout}
+	}
+	return writer
+}
+
+// Function to escape the commas, equal signs, and spaces of a tag value
+func escapeInfluxTag(value string) string {
+	return strings.NewReplacer(",", "\\,", "=", "\\=", " ", "\\ ", "\n", "\\n").Replace(value)
+}
+
+// Field of an InfluxDB line
+type influxField struct {
+	name  string
+	value float64
+	// Written as an integer field, like a count
+	integer bool
+}
+
+// Function to format a line of the measurement with the fields in order and a nanosecond timestamp
+func (writer *influxWriter) line(measurement string, timestamp time.Time, fields []influxField) string {
+	var line strings.Builder
+	line.WriteString(measurement + writer.tags)
+	for i, field := range fields {
+		if i == 0 {
+			line.WriteByte(' ')
+		} else {
+			line.WriteByte(',')
+		}
+		if field.integer {
+			line.WriteString(field.name + "=" + strconv.FormatInt(int64(field.value), 10) + "i")
+		} else {
+			line.WriteString(field.name + "=" + strconv.FormatFloat(field.value, 'f', -1, 64))
+		}
+	}
+	line.WriteString(" " + strconv.FormatInt(timestamp.UnixNano(), 10) + "\n")
+	return line.String()
+}
+
+// Function to format the test summary line with the request counts, latency percentiles, and throughput
+func (writer *influxWriter) summaryLine(result *Result, timestamp time.Time) string {
+	errorRate := 0.0
+	if result.TotalRequests > 0 {
+		errorRate = float64(result.FailedRequests) * 100 / float64(result.TotalRequests)
+	}
+	return writer.line(influxMeasurement, timestamp, []influxField{
+		{"threads", float64(result.Threads), true},
+		{"requests", float64(result.TotalRequests), true},
+		{"failed", float64(result.FailedRequests), true},
+		{"error_rate_percent", errorRate, false},
+		{"average_ms", result.AverageResponseTime, false},
+		{"p50_ms", result.P50ResponseTime, false},
+		{"p90_ms", result.P90ResponseTime, false},
+		{"p95_ms", result.P95ResponseTime, false},
+		{"p99_ms", result.P99ResponseTime, false},
+		{"requests_per_second", result.RequestsPerSecond, false},
+		{"total_time_sec", result.TotalTime, false},
+	})
+}
+
+// Function to format an -influxInterval line with the request rate and percentiles of the interval.  The samples
+// must be sorted.
+func (writer *influxWriter) intervalLine(timestamp time.Time, elapsed time.Duration, length time.Duration,
+	window *intervalWindow) string {
+	rps := 0.0
+	if length > 0 {
+		rps = float64(window.count) / length.Seconds()
+	}
+	return writer.line(influxIntervalMeasurement, timestamp, []influxField{
+		{"requests", float64(window.count), true},
+		{"elapsed_sec", elapsed.Seconds(), false},
+		{"requests_per_second", rps, false},
+		{"p50_ms", percentile(window.samples, 50), false},
+		{"p95_ms", percentile(window.samples, 95), false},
+		{"p99_ms", percentile(window.samples, 99), false},
+	})
+}
+
+// Function to append the lines to the -influxOut file and send them to the -influxUrl
+func (writer *influxWriter) write(lines string) error {
+	if writer.fileName != "" {
+		file, err := os.OpenFile(writer.fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(file, lines)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if writer.url != "" {
+		return writer.push(lines)
+	}
+	return nil
+}
+
+// Function to send the lines to the InfluxDB write URL, like "http://localhost:8086/api/v2/write?org=o&bucket=b"
+func (writer *influxWriter) push(lines string) error {
+	request, err := http.NewRequest(http.MethodPost, writer.url, strings.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if writer.token != "" {
+		request.Header.Set("Authorization", "Token "+writer.token)
+	}
+	resp, err := writer.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,113 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum response times kept per -interval.  Past the limit the samples are a uniform random subset.
+const intervalSampleLimit = 10000
+
+// Response times of the requests recorded in the current interval.  Guarded by the output mutex.
+type intervalWindow struct {
+	samples []float64
+	count   int
+}
+
+// Function to add a response time, replacing a random sample once the window is full (reservoir sampling)
+func (window *intervalWindow) add(responseTime float64) {
+	window.count++
+	if len(window.samples) < intervalSampleLimit {
+		window.samples = append(window.samples, responseTime)
+	} else if slot := rand.IntN(window.count); slot < intervalSampleLimit {
+		window.samples[slot] = responseTime
+	}
+}
+
+// Function to append a timestamped percentile snapshot to the -metricsFile, when set, and the -influxInterval
+// output, when not nil, every interval until done is closed.  Writes a last snapshot for the partial interval when
+// the test ends.
+func writeIntervalMetrics(mu *sync.Mutex, stats *testStats, fileName string, influx *influxWriter,
+	interval time.Duration, done <-chan struct{}, finished chan<- struct{}) {
+	defer close(finished)
+
+	var file *os.File
+	if fileName != "" {
+		var err error
+		file, err = os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Printf("Error: Opening the metrics file \"%s\" failed: %v\n", fileName, err)
+			return
+		}
+		defer func() { _ = file.Close() }()
+		if info, err := file.Stat(); err == nil && info.Size() == 0 {
+			_, _ = fmt.Fprintln(file, "timestamp,elapsedSec,requests,requestsPerSecond,p50Ms,p95Ms,p99Ms,label")
+		}
+	}
+
+	startTime := time.Now()
+	intervalStart := startTime
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		stopping := false
+		select {
+		case <-ticker.C:
+		case <-done:
+			stopping = true
+		}
+
+		mu.Lock()
+		window := stats.interval
+		stats.interval = intervalWindow{}
+		mu.Unlock()
+
+		now := time.Now()
+		sort.Float64s(window.samples)
+		if file != nil {
+			err := writeSnapshot(file, now, now.Sub(startTime), now.Sub(intervalStart), &window, stats.label)
+			if err != nil {
+				fmt.Printf("Error: Writing the metrics file \"%s\" failed: %v\n", fileName, err)
+				return
+			}
+		}
+		// A failed InfluxDB write stops the interval lines instead of failing every interval
+		if influx != nil {
+			if err := influx.write(influx.intervalLine(now, now.Sub(startTime), now.Sub(intervalStart), &window)); err != nil {
+				fmt.Printf("Error: Writing the InfluxDB interval metrics failed: %v\n", err)
+				influx = nil
+			}
+		}
+		intervalStart = now
+		if stopping {
+			return
+		}
+	}
+}
+
+// Function to write one CSV snapshot line with the request rate and percentiles of the interval.  The samples must be
+// sorted.
+func writeSnapshot(writer io.Writer, now time.Time, elapsed time.Duration, length time.Duration,
+	window *intervalWindow, label string) error {
+	rps := 0.0
+	if length > 0 {
+		rps = float64(window.count) / length.Seconds()
+	}
+	// A label with a comma or quote is quoted like the csv package does
+	if strings.ContainsAny(label, ",\"\r\n") {
+		label = "\"" + strings.ReplaceAll(label, "\"", "\"\"") + "\""
+	}
+	_, err := fmt.Fprintf(writer, "%s,%.3f,%d,%.2f,%.3f,%.3f,%.3f,%s\n", now.Format(time.RFC3339Nano),
+		elapsed.Seconds(), window.count, rps, percentile(window.samples, 50), percentile(window.samples, 95),
+		percentile(window.samples, 99), label)
+	return err
+}
@@ -0,0 +1,81 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import "time"
+
+// Per-thread clock of -jitterClock.  Measures how late each timed wait between the requests wakes up, which is the
+// scheduling delay of the load generator itself, and takes the delay off the next wait so the pacing does not drift.
+type jitterClock struct {
+	// Delay not yet taken off a wait
+	owed time.Duration
+	// Delay of each wait in milliseconds, in order
+	delays []float64
+}
+
+// Function to sleep for the duration less the owed delay and record how late the sleep woke up
+func (clock *jitterClock) sleep(duration time.Duration) {
+	wait := duration - clock.owed
+	if wait <= 0 {
+		clock.owed = -wait
+		return
+	}
+	startTime := time.Now()
+	time.Sleep(wait)
+	clock.owed = clock.record(time.Since(startTime) - wait)
+}
+
+// Function to record the delay of a wait.  Returns the delay, which is never negative.
+func (clock *jitterClock) record(delay time.Duration) time.Duration {
+	delay = max(delay, 0)
+	clock.delays = append(clock.delays, milliseconds(delay))
+	return delay
+}
+
+// Scheduling delays of the -jitterClock waits of all the threads
+type schedulingDelays struct {
+	count int
+	total float64
+	max   float64
+	// Delays of the first and second half of the waits of each thread, to tell a load generator falling behind
+	firstCount  int
+	firstTotal  float64
+	secondCount int
+	secondTotal float64
+}
+
+// Function to add the delays of a finished thread
+func (delays *schedulingDelays) add(clock *jitterClock) {
+	half := len(clock.delays) / 2
+	for i, delay := range clock.delays {
+		delays.count++
+		delays.total += delay
+		delays.max = max(delays.max, delay)
+		if i < half {
+			delays.firstCount++
+			delays.firstTotal += delay
+		} else {
+			delays.secondCount++
+			delays.secondTotal += delay
+		}
+	}
+}
+
+// Function to get the average delay of all the waits and of the first and second half of the waits
+func (delays *schedulingDelays) averages() (float64, float64, float64) {
+	average := func(total float64, count int) float64 {
+		if count == 0 {
+			return 0
+		}
+		return total / float64(count)
+	}
+	return average(delays.total, delays.count), average(delays.firstTotal, delays.firstCount),
+		average(delays.secondTotal, delays.secondCount)
+}
+
+// Function to check whether the scheduling delay grew enough during the test to show that the load generator could
+// not keep up, more than doubling to over a millisecond
+func schedulingFellBehind(firstHalf float64, secondHalf float64) bool {
+	return secondHalf > 1 && secondHalf > 2*firstHalf
+}
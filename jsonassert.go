@@ -0,0 +1,182 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Error for a response body that failed an -assertJSON check
+var errJSONAssertion = errors.New("JSON assertion failed")
+
+// Compiled -assertJSON check like "$.count > 0" or "$.status == 'ok'"
+type jsonAssertion struct {
+	text string
+	// Object keys and array indexes of the JSONPath, in order
+	path []any
+	// ==, !=, <, <=, >, or >=
+	operator string
+	// Expected number, string, bool, or nil for null
+	value any
+}
+
+// Comparison operators of an -assertJSON check, longest first so "<=" is not read as "<"
+var jsonOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// Function to compile an -assertJSON check.  The JSONPath supports $, .name, ['name'], and [index].  Numbers compare
+// with all the operators, and strings, true, false, and null with == and !=.
+func parseJSONAssertion(text string) (jsonAssertion, error) {
+	assertion := jsonAssertion{text: text}
+	index, operator := -1, ""
+	for _, candidate := range jsonOperators {
+		if i := strings.Index(text, candidate); i > 0 && (index < 0 || i < index) {
+			index, operator = i, candidate
+		}
+	}
+	if index < 0 {
+		return assertion, fmt.Errorf("missing a comparison operator")
+	}
+	assertion.operator = operator
+
+	var err error
+	if assertion.path, err = parseJSONPath(strings.TrimSpace(text[:index])); err != nil {
+		return assertion, err
+	}
+	value := strings.TrimSpace(text[index+len(operator):])
+	switch {
+	case value == "null":
+		assertion.value = nil
+	case value == "true" || value == "false":
+		assertion.value = value == "true"
+	case len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0]:
+		assertion.value = value[1 : len(value)-1]
+	default:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return assertion, fmt.Errorf("\"%s\" is not a valid value", value)
+		}
+		assertion.value = number
+	}
+	if _, isNumber := assertion.value.(float64); !isNumber && operator != "==" && operator != "!=" {
+		return assertion, fmt.Errorf("\"%s\" only supports == and != for a non-numeric value", operator)
+	}
+	return assertion, nil
+}
+
+// Function to compile a JSONPath like "$.items[0].name" into its keys and indexes
+func parseJSONPath(path string) ([]any, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("the JSONPath \"%s\" does not start with $", path)
+	}
+	var steps []any
+	for rest := path[1:]; rest != ""; {
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty name in the JSONPath \"%s\"", path)
+			}
+			steps = append(steps, rest[1:end+1])
+			rest = rest[end+1:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing \"]\" in the JSONPath \"%s\"", path)
+			}
+			inner := rest[1:end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, inner[1:len(inner)-1])
+			} else if arrayIndex, err := strconv.Atoi(inner); err == nil && arrayIndex >= 0 {
+				steps = append(steps, arrayIndex)
+			} else {
+				return nil, fmt.Errorf("\"%s\" is not a valid index in the JSONPath \"%s\"", inner, path)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected \"%s\" in the JSONPath \"%s\"", rest, path)
+		}
+	}
+	return steps, nil
+}
+
+// Function to find the value at the path in a decoded JSON document.  Returns false when it is not there.
+func lookupJSONPath(document any, path []any) (any, bool) {
+	value := document
+	for _, step := range path {
+		switch key := step.(type) {
+		case string:
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[key]; !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := value.([]any)
+			if !ok || key >= len(array) {
+				return nil, false
+			}
+			value = array[key]
+		}
+	}
+	return value, true
+}
+
+// Function to check the assertion against a decoded JSON document
+func (assertion *jsonAssertion) check(document any) error {
+	actual, found := lookupJSONPath(document, assertion.path)
+	if !found {
+		return fmt.Errorf("%w: %s has no value", errJSONAssertion, assertion.text)
+	}
+
+	passed := false
+	if expected, isNumber := assertion.value.(float64); isNumber {
+		number, ok := actual.(float64)
+		if !ok {
+			return fmt.Errorf("%w: %s is not a number", errJSONAssertion, assertion.text)
+		}
+		switch assertion.operator {
+		case "==":
+			passed = number == expected
+		case "!=":
+			passed = number != expected
+		case "<":
+			passed = number < expected
+		case "<=":
+			passed = number <= expected
+		case ">":
+			passed = number > expected
+		case ">=":
+			passed = number >= expected
+		}
+	} else {
+		passed = (actual == assertion.value) == (assertion.operator == "==")
+	}
+	if !passed {
+		return fmt.Errorf("%w: %s, actual %v", errJSONAssertion, assertion.text, actual)
+	}
+	return nil
+}
+
+// Function to check the response body against all the assertions.  Returns the first failure.
+func checkJSONAssertions(assertions []jsonAssertion, body []byte) error {
+	var document any
+	if err := json.Unmarshal(body, &document); err != nil {
+		return fmt.Errorf("%w: %w", errJSONAssertion, errInvalidJSON)
+	}
+	for i := range assertions {
+		if err := assertions[i].check(document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Stream of one JSON object per completed request for -jsonlOut.  A single writer goroutine serializes the lines so
+// the request threads do not wait on the output.
+type jsonlWriter struct {
+	results chan requestResult
+	done    chan struct{}
+	err     error
+}
+
+// Function to start writing the request results as JSON Lines to the writer
+func newJSONLWriter(writer io.Writer) *jsonlWriter {
+	jsonl := &jsonlWriter{results: make(chan requestResult, 1024), done: make(chan struct{})}
+	go func() {
+		defer close(jsonl.done)
+		// Each line is a separate write so a process tailing the stream sees the requests as they finish
+		encoder := json.NewEncoder(writer)
+		for result := range jsonl.results {
+			if err := encoder.Encode(&result); err != nil && jsonl.err == nil {
+				jsonl.err = err
+			}
+		}
+	}()
+	return jsonl
+}
+
+// Function to queue a request result for writing.  The caller must hold the output mutex.
+func (jsonl *jsonlWriter) write(result *requestResult) {
+	jsonl.results <- *result
+}
+
+// Function to write the queued results and stop the writer.  Returns the first write error.
+func (jsonl *jsonlWriter) close() error {
+	close(jsonl.results)
+	<-jsonl.done
+	return jsonl.err
+}
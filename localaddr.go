@@ -0,0 +1,91 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Number of -localPortRange ports tried for a connection before it fails
+const localPortAttempts = 10
+
+// Error of a connection that found no free local port or address to bind
+var errLocalPortsExhausted = errors.New("no local port or address available to bind")
+
+// Function to parse a -localPortRange value like "20000-29999".  Returns the first and last port.
+func parsePortRange(value string) (int, int, error) {
+	startText, endText, found := strings.Cut(value, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("expected \"start-end\"")
+	}
+	start, startErr := strconv.Atoi(startText)
+	end, endErr := strconv.Atoi(endText)
+	if startErr != nil || endErr != nil || start < 1 || end > 65535 || start > end {
+		return 0, 0, fmt.Errorf("the ports must be from 1 to 65535 with the start before the end")
+	}
+	return start, end, nil
+}
+
+// Function to parse a comma-separated -localAddresses list of IP addresses
+func parseLocalAddresses(value string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, text := range strings.Split(value, ",") {
+		ip := net.ParseIP(strings.TrimSpace(text))
+		if ip == nil {
+			return nil, fmt.Errorf("\"%s\" is not a valid IP address", text)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// Function to get the next local address to bind in rotation over the -localAddresses and -localPortRange
+func (d *connDialer) nextLocalAddr() *net.TCPAddr {
+	n := int(d.nextLocal.Add(1) - 1)
+	addr := &net.TCPAddr{}
+	if len(d.localIPs) > 0 {
+		addr.IP = d.localIPs[n%len(d.localIPs)]
+		n /= len(d.localIPs)
+	}
+	if d.lastPort > 0 {
+		addr.Port = d.firstPort + n%(d.lastPort-d.firstPort+1)
+	}
+	return addr
+}
+
+// Function to dial from the next local address.  A port that is still in use, usually in TIME_WAIT from an earlier
+// connection, is skipped for the next one.  Bind and ephemeral port failures are reported as local port exhaustion
+// instead of as generic connection failures.
+func (d *connDialer) dialLocal(ctx context.Context, network string, address string) (net.Conn, error) {
+	if d.lastPort == 0 && len(d.localIPs) == 0 {
+		conn, err := d.dialer.DialContext(ctx, network, address)
+		return conn, localPortError(err)
+	}
+
+	var err error
+	for attempt := 0; attempt < localPortAttempts; attempt++ {
+		dialer := d.dialer
+		dialer.LocalAddr = d.nextLocalAddr()
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, address)
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			return conn, localPortError(err)
+		}
+	}
+	return nil, fmt.Errorf("%w: %d ports in use: %w", errLocalPortsExhausted, localPortAttempts, err)
+}
+
+// Function to mark the errors of a connection that could not get a local port or address
+func localPortError(err error) error {
+	if errors.Is(err, syscall.EADDRNOTAVAIL) || errors.Is(err, syscall.EADDRINUSE) {
+		return fmt.Errorf("%w: %w", errLocalPortsExhausted, err)
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"runtime"
+	"time"
+)
+
+// Interval between the -runtimeStats samples.  ReadMemStats briefly stops the world, so it is not sampled often.
+const memStatsInterval = 250 * time.Millisecond
+
+// Garbage collector and heap statistics of the tester itself over the test
+type memStatsSampler struct {
+	start    runtime.MemStats
+	peakHeap uint64
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Function to start sampling the runtime memory statistics in the background
+func startMemStats() *memStatsSampler {
+	sampler := &memStatsSampler{stop: make(chan struct{}), done: make(chan struct{})}
+	runtime.ReadMemStats(&sampler.start)
+	sampler.peakHeap = sampler.start.HeapAlloc
+
+	go func() {
+		defer close(sampler.done)
+		ticker := time.NewTicker(memStatsInterval)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			select {
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				sampler.peakHeap = max(sampler.peakHeap, stats.HeapAlloc)
+			case <-sampler.stop:
+				return
+			}
+		}
+	}()
+	return sampler
+}
+
+// Function to stop sampling and set the number of GCs, total GC pause time, and peak heap since the start
+func (sampler *memStatsSampler) finish(result *Result) {
+	close(sampler.stop)
+	<-sampler.done
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	result.GCCount = stats.NumGC - sampler.start.NumGC
+	result.GCPauseTime = float64(stats.PauseTotalNs-sampler.start.PauseTotalNs) / 1e6
+	result.PeakHeapBytes = max(sampler.peakHeap, stats.HeapAlloc)
+}
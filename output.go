@@ -0,0 +1,38 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// Output file compressed with gzip as it is written
+type gzipFile struct {
+	*gzip.Writer
+	file *os.File
+}
+
+// Function to write the end of the gzip stream and close the file.  Returns the first error.
+func (out *gzipFile) Close() error {
+	err := out.Writer.Close()
+	if closeErr := out.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Function to create a per-request output file.  A file name ending in ".gz" is compressed with gzip.
+func createOutput(fileName string) (io.WriteCloser, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(fileName, ".gz") {
+		return &gzipFile{Writer: gzip.NewWriter(file), file: file}, nil
+	}
+	return file, nil
+}
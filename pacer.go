@@ -0,0 +1,87 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import "time"
+
+// Request rate limit of a single thread for -maxRPSPerThread
+type threadPacer struct {
+	// Time between the request starts
+	interval time.Duration
+	// Earliest start of the next request
+	next time.Time
+	// Start times of the first and last request and the number of requests, to measure the achieved rate
+	first time.Time
+	last  time.Time
+	count int
+	// Records the wake-up delays and keeps the starts on the schedule, nil without -jitterClock
+	clock *jitterClock
+}
+
+// Function to create a pacer for the requests per second
+func newThreadPacer(rate float64) *threadPacer {
+	return &threadPacer{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// Function to wait for the next request start.  A request that starts late does not let the next ones catch up, so
+// the thread never goes over the rate.  With the -jitterClock a late wake-up from the wait is recorded and the next
+// start is kept on the schedule.  Returns false when the test is stopped first.
+func (pacer *threadPacer) wait(done <-chan struct{}) bool {
+	scheduled := pacer.next
+	if delay := time.Until(scheduled); delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			return false
+		}
+	} else {
+		// A start already due is late because of the previous response, not the wait
+		scheduled = time.Time{}
+	}
+	now := time.Now()
+	pacer.next = now.Add(pacer.interval)
+	if pacer.clock != nil && !scheduled.IsZero() {
+		pacer.clock.record(now.Sub(scheduled))
+		pacer.next = scheduled.Add(pacer.interval)
+	}
+	if pacer.count == 0 {
+		pacer.first = now
+	}
+	pacer.last = now
+	pacer.count++
+	return true
+}
+
+// Function to get the achieved requests per second from the request starts.  Returns false with fewer than two
+// requests.
+func (pacer *threadPacer) rate() (float64, bool) {
+	elapsed := pacer.last.Sub(pacer.first).Seconds()
+	if pacer.count < 2 || elapsed <= 0 {
+		return 0, false
+	}
+	return float64(pacer.count-1) / elapsed, true
+}
+
+// Requests per second achieved by the -maxRPSPerThread threads
+type threadRates struct {
+	// The -maxRPSPerThread cap
+	limit float64
+	rates []float64
+}
+
+// Function to get the average, min, and max achieved rate
+func (rates *threadRates) summarize() (float64, float64, float64) {
+	if len(rates.rates) == 0 {
+		return 0, 0, 0
+	}
+	total, minRate, maxRate := 0.0, rates.rates[0], rates.rates[0]
+	for _, rate := range rates.rates {
+		total += rate
+		minRate = min(minRate, rate)
+		maxRate = max(maxRate, rate)
+	}
+	return total / float64(len(rates.rates)), minRate, maxRate
+}
@@ -0,0 +1,236 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Success predicate used when -success is not set
+const defaultSuccess = "status==2xx"
+
+// Compiled -success expression over the result of a request
+type successPredicate struct {
+	text string
+	eval func(result *requestResult, body []byte) bool
+	// The expression reads the response body, so the body must be kept
+	needsBody bool
+}
+
+// Parser state for a -success expression
+type predicateParser struct {
+	tokens    []string
+	pos       int
+	needsBody bool
+}
+
+// Function to compile a -success expression, like "status==200 && latency<500ms && body.contains('ok')".
+// Supports status, latency, bytes, and retries compared with ==, !=, <, <=, >, or >=, body.contains('text'), !, &&,
+// ||, and parentheses.  Status also compares with a class, like 2xx.
+func parsePredicate(text string) (*successPredicate, error) {
+	tokens, err := tokenizePredicate(text)
+	if err != nil {
+		return nil, err
+	}
+	parser := &predicateParser{tokens: tokens}
+	eval, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("unexpected \"%s\"", parser.tokens[parser.pos])
+	}
+	return &successPredicate{text: text, eval: eval, needsBody: parser.needsBody}, nil
+}
+
+// Function to split a -success expression into words, quoted strings, and operators
+func tokenizePredicate(text string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(text[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, text[i:i+end+2])
+			i += end + 2
+		case strings.HasPrefix(text[i:], "&&") || strings.HasPrefix(text[i:], "||") ||
+			strings.HasPrefix(text[i:], "==") || strings.HasPrefix(text[i:], "!=") ||
+			strings.HasPrefix(text[i:], "<=") || strings.HasPrefix(text[i:], ">="):
+			tokens = append(tokens, text[i:i+2])
+			i += 2
+		case strings.IndexByte("()!<>", c) >= 0:
+			tokens = append(tokens, text[i:i+1])
+			i++
+		default:
+			start := i
+			for i < len(text) && strings.IndexByte(" \t'\"&|=!<>()", text[i]) < 0 {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected \"%c\"", c)
+			}
+			tokens = append(tokens, text[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+// Function to get the next token without consuming it.  Returns an empty string at the end.
+func (parser *predicateParser) peek() string {
+	if parser.pos < len(parser.tokens) {
+		return parser.tokens[parser.pos]
+	}
+	return ""
+}
+
+// Function to consume the next token, which must be the expected token
+func (parser *predicateParser) expect(token string) error {
+	if parser.peek() != token {
+		return fmt.Errorf("expected \"%s\"", token)
+	}
+	parser.pos++
+	return nil
+}
+
+// Function to parse expressions joined by ||
+func (parser *predicateParser) parseOr() (func(*requestResult, []byte) bool, error) {
+	left, err := parser.parseAnd()
+	for err == nil && parser.peek() == "||" {
+		parser.pos++
+		var right func(*requestResult, []byte) bool
+		if right, err = parser.parseAnd(); err == nil {
+			first := left
+			left = func(result *requestResult, body []byte) bool { return first(result, body) || right(result, body) }
+		}
+	}
+	return left, err
+}
+
+// Function to parse expressions joined by &&
+func (parser *predicateParser) parseAnd() (func(*requestResult, []byte) bool, error) {
+	left, err := parser.parseUnary()
+	for err == nil && parser.peek() == "&&" {
+		parser.pos++
+		var right func(*requestResult, []byte) bool
+		if right, err = parser.parseUnary(); err == nil {
+			first := left
+			left = func(result *requestResult, body []byte) bool { return first(result, body) && right(result, body) }
+		}
+	}
+	return left, err
+}
+
+// Function to parse a negation, a parenthesized expression, body.contains, or a comparison
+func (parser *predicateParser) parseUnary() (func(*requestResult, []byte) bool, error) {
+	token := parser.peek()
+	parser.pos++
+	switch token {
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case "!":
+		inner, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(result *requestResult, body []byte) bool { return !inner(result, body) }, nil
+	case "(":
+		inner, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, parser.expect(")")
+	case "body.contains":
+		if err := parser.expect("("); err != nil {
+			return nil, err
+		}
+		text := parser.peek()
+		if len(text) < 2 || (text[0] != '\'' && text[0] != '"') {
+			return nil, fmt.Errorf("body.contains needs a quoted string")
+		}
+		parser.pos++
+		if err := parser.expect(")"); err != nil {
+			return nil, err
+		}
+		parser.needsBody = true
+		wanted := []byte(text[1 : len(text)-1])
+		return func(result *requestResult, body []byte) bool { return bytes.Contains(body, wanted) }, nil
+	}
+	return parser.parseComparison(token)
+}
+
+// Function to parse the operator and value of a comparison with a result field
+func (parser *predicateParser) parseComparison(field string) (func(*requestResult, []byte) bool, error) {
+	operator := parser.peek()
+	if operator != "==" && operator != "!=" && operator != "<" && operator != "<=" && operator != ">" &&
+		operator != ">=" {
+		return nil, fmt.Errorf("expected a comparison after \"%s\"", field)
+	}
+	parser.pos++
+	value := parser.peek()
+	parser.pos++
+
+	// A status class, like 2xx, only compares for equality
+	if field == "status" && len(value) == 3 && strings.HasSuffix(value, "xx") && value[0] >= '1' && value[0] <= '5' {
+		if operator != "==" && operator != "!=" {
+			return nil, fmt.Errorf("status class \"%s\" only supports == and !=", value)
+		}
+		class := int(value[0] - '0')
+		equal := operator == "=="
+		return func(result *requestResult, _ []byte) bool { return (result.StatusCode/100 == class) == equal }, nil
+	}
+
+	var get func(result *requestResult) float64
+	var limit float64
+	var err error
+	switch field {
+	case "status":
+		get = func(result *requestResult) float64 { return float64(result.StatusCode) }
+		limit, err = strconv.ParseFloat(value, 64)
+	case "bytes":
+		get = func(result *requestResult) float64 { return float64(result.Bytes) }
+		limit, err = strconv.ParseFloat(value, 64)
+	case "retries":
+		get = func(result *requestResult) float64 { return float64(result.Retries) }
+		limit, err = strconv.ParseFloat(value, 64)
+	case "latency":
+		get = func(result *requestResult) float64 { return result.ResponseTime }
+		// Milliseconds without a unit
+		if limit, err = strconv.ParseFloat(value, 64); err != nil {
+			var duration time.Duration
+			duration, err = time.ParseDuration(value)
+			limit = milliseconds(duration)
+		}
+	default:
+		return nil, fmt.Errorf("unknown field \"%s\"", field)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("\"%s\" is not a valid value for %s", value, field)
+	}
+
+	return func(result *requestResult, _ []byte) bool {
+		actual := get(result)
+		switch operator {
+		case "==":
+			return actual == limit
+		case "!=":
+			return actual != limit
+		case "<":
+			return actual < limit
+		case "<=":
+			return actual <= limit
+		case ">":
+			return actual > limit
+		}
+		return actual >= limit
+	}, nil
+}
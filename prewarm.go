@@ -0,0 +1,43 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Function to open count connections before the timed test by sending that many concurrent HEAD requests, which
+// leaves the connections idle in the client pool.  Returns the prewarm time and the number of requests that
+// succeeded.
+func prewarmConnections(ctx context.Context, httpClient *http.Client, url string, count int) (time.Duration, int) {
+	var wg sync.WaitGroup
+	var warmed atomic.Int64
+	startTime := time.Now()
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+			if err != nil {
+				return
+			}
+			request.Header.Set("Connection", "keep-alive")
+			resp, err := httpClient.Do(request)
+			if err != nil {
+				return
+			}
+			// Drain the body so the connection goes back to the pool
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			warmed.Add(1)
+		}()
+	}
+	wg.Wait()
+	return time.Since(startTime), int(warmed.Load())
+}
@@ -0,0 +1,624 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Content type of the -protoBody request bodies
+const protobufContentType = "application/x-protobuf"
+
+// Field types of a FieldDescriptorProto
+const (
+	protoDouble   = 1
+	protoFloat    = 2
+	protoInt64    = 3
+	protoUint64   = 4
+	protoInt32    = 5
+	protoFixed64  = 6
+	protoFixed32  = 7
+	protoBool     = 8
+	protoString   = 9
+	protoGroup    = 10
+	protoMessage  = 11
+	protoBytes    = 12
+	protoUint32   = 13
+	protoEnum     = 14
+	protoSfixed32 = 15
+	protoSfixed64 = 16
+	protoSint32   = 17
+	protoSint64   = 18
+)
+
+// Wire types of the protobuf encoding
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireLen    = 2
+	wireI32    = 5
+)
+
+// Message and enum types of a -protoDescriptor file descriptor set, by their full names without the leading dot
+type protoSchema struct {
+	messages map[string]*protoMessageType
+	enums    map[string]map[string]int32
+}
+
+// Message type of a descriptor
+type protoMessageType struct {
+	name string
+	// Fields in field number order
+	fields []*protoField
+	// Generated entry type of a map field
+	mapEntry bool
+}
+
+// Field of a message type
+type protoField struct {
+	name     string
+	jsonName string
+	number   int
+	kind     int
+	repeated bool
+	// Repeated scalars are written in a single length-delimited record
+	packed bool
+	// Full name of the message or enum type, resolved to message or enumValues after loading
+	typeName   string
+	message    *protoMessageType
+	enumValues map[string]int32
+}
+
+// Protobuf request body encoded from a JSON message for -protoBody
+type protoBody struct {
+	message *protoMessageType
+	json    string
+	// The JSON has {{thread}} or {{iteration}} placeholders, so it is encoded for every request
+	templated bool
+}
+
+// Function to read the fields of a protobuf message, calling the function with the field number, the wire type, and
+// the varint or fixed value or the length-delimited bytes
+func readProtoFields(data []byte, field func(number int, wireType int, value uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("invalid field tag")
+		}
+		data = data[n:]
+		number, wireType := int(tag>>3), int(tag&7)
+		var value uint64
+		var payload []byte
+		switch wireType {
+		case wireVarint:
+			if value, n = binary.Uvarint(data); n <= 0 {
+				return fmt.Errorf("invalid varint in field %d", number)
+			}
+			data = data[n:]
+		case wireI64:
+			if len(data) < 8 {
+				return fmt.Errorf("truncated field %d", number)
+			}
+			value, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireI32:
+			if len(data) < 4 {
+				return fmt.Errorf("truncated field %d", number)
+			}
+			value, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case wireLen:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return fmt.Errorf("truncated field %d", number)
+			}
+			payload, data = data[n:n+int(length)], data[n+int(length):]
+		default:
+			return fmt.Errorf("unsupported wire type %d in field %d", wireType, number)
+		}
+		if err := field(number, wireType, value, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to load a binary FileDescriptorSet, like the output of protoc --include_imports --descriptor_set_out
+func loadProtoDescriptor(fileName string) (*protoSchema, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	schema := &protoSchema{messages: make(map[string]*protoMessageType), enums: make(map[string]map[string]int32)}
+	err = readProtoFields(data, func(number int, wireType int, _ uint64, file []byte) error {
+		if number == 1 && wireType == wireLen {
+			return schema.addFile(file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("the file is not a valid descriptor set: %w", err)
+	}
+	if len(schema.messages) == 0 {
+		return nil, fmt.Errorf("the file has no message types")
+	}
+
+	// Resolve the message and enum field types once every file is loaded
+	for _, message := range schema.messages {
+		for _, field := range message.fields {
+			switch field.kind {
+			case protoMessage:
+				if field.message = schema.messages[field.typeName]; field.message == nil {
+					return nil, fmt.Errorf("unknown message type \"%s\" of %s.%s", field.typeName, message.name,
+						field.name)
+				}
+			case protoEnum:
+				if field.enumValues = schema.enums[field.typeName]; field.enumValues == nil {
+					return nil, fmt.Errorf("unknown enum type \"%s\" of %s.%s", field.typeName, message.name,
+						field.name)
+				}
+			case protoGroup:
+				return nil, fmt.Errorf("the group field %s.%s is not supported", message.name, field.name)
+			}
+		}
+	}
+	return schema, nil
+}
+
+// Function to add the message and enum types of a FileDescriptorProto
+func (schema *protoSchema) addFile(data []byte) error {
+	var packageName, syntax string
+	var messages, enums [][]byte
+	err := readProtoFields(data, func(number int, wireType int, _ uint64, payload []byte) error {
+		switch {
+		case number == 2 && wireType == wireLen:
+			packageName = string(payload)
+		case number == 4 && wireType == wireLen:
+			messages = append(messages, payload)
+		case number == 5 && wireType == wireLen:
+			enums = append(enums, payload)
+		case number == 12 && wireType == wireLen:
+			syntax = string(payload)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Repeated scalars are packed by default from proto3 on
+	packedDefault := syntax != "" && syntax != "proto2"
+	for _, message := range messages {
+		if err := schema.addMessage(packageName, message, packedDefault); err != nil {
+			return err
+		}
+	}
+	for _, enum := range enums {
+		if err := schema.addEnum(packageName, enum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to join a scope and a type name into a full type name
+func protoFullName(scope string, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// Function to add a DescriptorProto and its nested types
+func (schema *protoSchema) addMessage(scope string, data []byte, packedDefault bool) error {
+	message := &protoMessageType{}
+	var fields, nested, enums [][]byte
+	err := readProtoFields(data, func(number int, wireType int, _ uint64, payload []byte) error {
+		if wireType != wireLen {
+			return nil
+		}
+		switch number {
+		case 1:
+			message.name = string(payload)
+		case 2:
+			fields = append(fields, payload)
+		case 3:
+			nested = append(nested, payload)
+		case 4:
+			enums = append(enums, payload)
+		case 7:
+			// MessageOptions.map_entry
+			return readProtoFields(payload, func(number int, wireType int, value uint64, _ []byte) error {
+				if number == 7 && wireType == wireVarint {
+					message.mapEntry = value != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	message.name = protoFullName(scope, message.name)
+
+	for _, data := range fields {
+		field, err := parseProtoField(data, packedDefault)
+		if err != nil {
+			return fmt.Errorf("%s: %w", message.name, err)
+		}
+		message.fields = append(message.fields, field)
+	}
+	sort.Slice(message.fields, func(i, j int) bool { return message.fields[i].number < message.fields[j].number })
+	schema.messages[message.name] = message
+
+	for _, data := range nested {
+		if err := schema.addMessage(message.name, data, packedDefault); err != nil {
+			return err
+		}
+	}
+	for _, data := range enums {
+		if err := schema.addEnum(message.name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to parse a FieldDescriptorProto
+func parseProtoField(data []byte, packedDefault bool) (*protoField, error) {
+	field := &protoField{packed: packedDefault}
+	err := readProtoFields(data, func(number int, wireType int, value uint64, payload []byte) error {
+		switch {
+		case number == 1 && wireType == wireLen:
+			field.name = string(payload)
+		case number == 3 && wireType == wireVarint:
+			field.number = int(value)
+		case number == 4 && wireType == wireVarint:
+			field.repeated = value == 3
+		case number == 5 && wireType == wireVarint:
+			field.kind = int(value)
+		case number == 6 && wireType == wireLen:
+			field.typeName = strings.TrimPrefix(string(payload), ".")
+		case number == 10 && wireType == wireLen:
+			field.jsonName = string(payload)
+		case number == 8 && wireType == wireLen:
+			// FieldOptions.packed
+			return readProtoFields(payload, func(number int, wireType int, value uint64, _ []byte) error {
+				if number == 2 && wireType == wireVarint {
+					field.packed = value != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if field.name == "" || field.number <= 0 || field.kind == 0 {
+		return nil, fmt.Errorf("incomplete field descriptor")
+	}
+	if field.jsonName == "" {
+		field.jsonName = field.name
+	}
+	return field, nil
+}
+
+// Function to add an EnumDescriptorProto
+func (schema *protoSchema) addEnum(scope string, data []byte) error {
+	name := ""
+	values := make(map[string]int32)
+	err := readProtoFields(data, func(number int, wireType int, _ uint64, payload []byte) error {
+		switch {
+		case number == 1 && wireType == wireLen:
+			name = string(payload)
+		case number == 2 && wireType == wireLen:
+			valueName, valueNumber := "", int32(0)
+			err := readProtoFields(payload, func(number int, wireType int, value uint64, payload []byte) error {
+				if number == 1 && wireType == wireLen {
+					valueName = string(payload)
+				} else if number == 2 && wireType == wireVarint {
+					valueNumber = int32(value)
+				}
+				return nil
+			})
+			values[valueName] = valueNumber
+			return err
+		}
+		return nil
+	})
+	schema.enums[protoFullName(scope, name)] = values
+	return err
+}
+
+// Function to create a -protoBody of the message type from the JSON or "@file".  The JSON is encoded once here, so
+// encoding errors are found before the test, and again for every request when it has placeholders.
+func newProtoBody(schema *protoSchema, messageName string, value string) (*protoBody, []byte, error) {
+	message := schema.messages[strings.TrimPrefix(messageName, ".")]
+	if message == nil {
+		return nil, nil, fmt.Errorf("the descriptor has no message type \"%s\"", messageName)
+	}
+	text := value
+	if strings.HasPrefix(value, "@") {
+		data, err := os.ReadFile(value[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		text = string(data)
+	}
+	body := &protoBody{message: message, json: text}
+	body.templated = strings.Contains(text, "{{thread}}") || strings.Contains(text, "{{iteration}}")
+	data, err := body.encode(0, 0)
+	return body, data, err
+}
+
+// Function to encode the JSON message with the placeholders replaced by the thread and iteration
+func (body *protoBody) encode(threadID int, iteration int) ([]byte, error) {
+	text := body.json
+	if body.templated {
+		text = strings.NewReplacer("{{thread}}", strconv.Itoa(threadID),
+			"{{iteration}}", strconv.Itoa(iteration)).Replace(text)
+	}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	// Keep the 64-bit integers exact
+	decoder.UseNumber()
+	var object map[string]any
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("the message is not a valid JSON object: %w", err)
+	}
+	return encodeProtoMessage(nil, body.message, object)
+}
+
+// Function to append the protobuf encoding of a JSON object of the message type.  The JSON names are the field
+// names or their lowerCamelCase JSON names, like the protobuf JSON mapping.
+func encodeProtoMessage(out []byte, message *protoMessageType, object map[string]any) ([]byte, error) {
+	used := 0
+	for _, field := range message.fields {
+		value, found := object[field.jsonName]
+		if !found {
+			value, found = object[field.name]
+		}
+		if !found {
+			continue
+		}
+		used++
+		if value == nil {
+			continue
+		}
+		var err error
+		if out, err = appendProtoField(out, field, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", field.name, err)
+		}
+	}
+	if used < len(object) {
+		for key := range object {
+			if !message.hasField(key) {
+				return nil, fmt.Errorf("%s has no field \"%s\"", message.name, key)
+			}
+		}
+	}
+	return out, nil
+}
+
+// Function to check if the field or JSON name belongs to the message type
+func (message *protoMessageType) hasField(name string) bool {
+	for _, field := range message.fields {
+		if field.name == name || field.jsonName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to append a field with a single, repeated, or map value
+func appendProtoField(out []byte, field *protoField, value any) ([]byte, error) {
+	if field.message != nil && field.message.mapEntry {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected a JSON object for the map")
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			// JSON object keys are strings, so a bool key is converted.  The integer keys parse from strings already.
+			var entryKey any = key
+			if len(field.message.fields) > 0 && field.message.fields[0].kind == protoBool {
+				if key != "true" && key != "false" {
+					return nil, fmt.Errorf("the map key \"%s\" is not true or false", key)
+				}
+				entryKey = key == "true"
+			}
+			entry, err := encodeProtoMessage(nil, field.message, map[string]any{"key": entryKey, "value": object[key]})
+			if err != nil {
+				return nil, err
+			}
+			out = appendProtoLen(out, field.number, entry)
+		}
+		return out, nil
+	}
+	if !field.repeated {
+		return appendProtoValue(out, field, value)
+	}
+
+	values, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a JSON array")
+	}
+	if field.packed && protoWireType(field.kind) != wireLen {
+		var packed []byte
+		for _, item := range values {
+			var err error
+			if packed, err = appendProtoScalar(packed, field, item); err != nil {
+				return nil, err
+			}
+		}
+		return appendProtoLen(out, field.number, packed), nil
+	}
+	for _, item := range values {
+		var err error
+		if out, err = appendProtoValue(out, field, item); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Function to get the wire type of a field type
+func protoWireType(kind int) int {
+	switch kind {
+	case protoDouble, protoFixed64, protoSfixed64:
+		return wireI64
+	case protoFloat, protoFixed32, protoSfixed32:
+		return wireI32
+	case protoString, protoBytes, protoMessage:
+		return wireLen
+	}
+	return wireVarint
+}
+
+// Function to append a length-delimited field
+func appendProtoLen(out []byte, number int, data []byte) []byte {
+	out = binary.AppendUvarint(out, uint64(number)<<3|wireLen)
+	out = binary.AppendUvarint(out, uint64(len(data)))
+	return append(out, data...)
+}
+
+// Function to append a single value with its field tag
+func appendProtoValue(out []byte, field *protoField, value any) ([]byte, error) {
+	switch field.kind {
+	case protoString:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a JSON string")
+		}
+		return appendProtoLen(out, field.number, []byte(text)), nil
+	case protoBytes:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a base64 JSON string")
+		}
+		data, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			if data, err = base64.URLEncoding.DecodeString(text); err != nil {
+				return nil, fmt.Errorf("\"%s\" is not valid base64", text)
+			}
+		}
+		return appendProtoLen(out, field.number, data), nil
+	case protoMessage:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected a JSON object")
+		}
+		data, err := encodeProtoMessage(nil, field.message, object)
+		if err != nil {
+			return nil, err
+		}
+		return appendProtoLen(out, field.number, data), nil
+	}
+	out = binary.AppendUvarint(out, uint64(field.number)<<3|uint64(protoWireType(field.kind)))
+	return appendProtoScalar(out, field, value)
+}
+
+// Function to append a number, bool, or enum value without its field tag.  Numbers may be JSON numbers or strings,
+// like the 64-bit integers of the protobuf JSON mapping.
+func appendProtoScalar(out []byte, field *protoField, value any) ([]byte, error) {
+	switch field.kind {
+	case protoBool:
+		flag, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected true or false")
+		}
+		if flag {
+			return append(out, 1), nil
+		}
+		return append(out, 0), nil
+	case protoEnum:
+		if name, ok := value.(string); ok {
+			number, found := field.enumValues[name]
+			if !found {
+				return nil, fmt.Errorf("\"%s\" is not a value of %s", name, field.typeName)
+			}
+			return binary.AppendUvarint(out, uint64(int64(number))), nil
+		}
+		number, err := protoInteger(value, 32, true)
+		return binary.AppendUvarint(out, number), err
+	case protoDouble, protoFloat:
+		text, err := protoNumberText(value)
+		if err != nil {
+			return nil, err
+		}
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("\"%s\" is not a valid number", text)
+		}
+		if field.kind == protoFloat {
+			return binary.LittleEndian.AppendUint32(out, math.Float32bits(float32(number))), nil
+		}
+		return binary.LittleEndian.AppendUint64(out, math.Float64bits(number)), nil
+	}
+
+	signed := field.kind != protoUint32 && field.kind != protoUint64 && field.kind != protoFixed32 &&
+		field.kind != protoFixed64
+	bits := 64
+	if field.kind == protoInt32 || field.kind == protoUint32 || field.kind == protoSint32 ||
+		field.kind == protoFixed32 || field.kind == protoSfixed32 {
+		bits = 32
+	}
+	number, err := protoInteger(value, bits, signed)
+	if err != nil {
+		return nil, err
+	}
+	switch field.kind {
+	case protoSint32, protoSint64:
+		// Zigzag so small negative numbers stay short
+		signedNumber := int64(number)
+		return binary.AppendUvarint(out, uint64(signedNumber<<1^signedNumber>>63)), nil
+	case protoFixed32, protoSfixed32:
+		return binary.LittleEndian.AppendUint32(out, uint32(number)), nil
+	case protoFixed64, protoSfixed64:
+		return binary.LittleEndian.AppendUint64(out, number), nil
+	}
+	return binary.AppendUvarint(out, number), nil
+}
+
+// Function to get the text of a JSON number or numeric string
+func protoNumberText(value any) (string, error) {
+	switch number := value.(type) {
+	case json.Number:
+		return number.String(), nil
+	case string:
+		return number, nil
+	}
+	return "", fmt.Errorf("expected a number")
+}
+
+// Function to parse an integer of the size, returning a negative signed integer sign-extended to 64 bits like the
+// varint encoding
+func protoInteger(value any, bits int, signed bool) (uint64, error) {
+	text, err := protoNumberText(value)
+	if err != nil {
+		return 0, err
+	}
+	if signed {
+		number, err := strconv.ParseInt(text, 10, bits)
+		if err != nil {
+			return 0, fmt.Errorf("\"%s\" is not a valid %d-bit integer", text, bits)
+		}
+		return uint64(number), nil
+	}
+	number, err := strconv.ParseUint(text, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("\"%s\" is not a valid unsigned %d-bit integer", text, bits)
+	}
+	return number, nil
+}
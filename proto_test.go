@@ -0,0 +1,154 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Function to append a varint record of a descriptor
+func descriptorVarint(out []byte, number int, value uint64) []byte {
+	out = binary.AppendUvarint(out, uint64(number)<<3|wireVarint)
+	return binary.AppendUvarint(out, value)
+}
+
+// Function to append a length-delimited record of a descriptor
+func descriptorLen(out []byte, number int, data string) []byte {
+	out = binary.AppendUvarint(out, uint64(number)<<3|wireLen)
+	out = binary.AppendUvarint(out, uint64(len(data)))
+	return append(out, data...)
+}
+
+// Function to create a FieldDescriptorProto, with label 1 optional or 3 repeated
+func descriptorField(name string, number int, label int, kind int, typeName string) string {
+	field := descriptorLen(nil, 1, name)
+	field = descriptorVarint(field, 3, uint64(number))
+	field = descriptorVarint(field, 4, uint64(label))
+	field = descriptorVarint(field, 5, uint64(kind))
+	if typeName != "" {
+		field = descriptorLen(field, 6, typeName)
+	}
+	return string(field)
+}
+
+// Function to create a DescriptorProto of the fields and nested messages
+func descriptorMessage(name string, mapEntry bool, fields []string, nested ...string) string {
+	message := descriptorLen(nil, 1, name)
+	for _, field := range fields {
+		message = descriptorLen(message, 2, field)
+	}
+	for _, data := range nested {
+		message = descriptorLen(message, 3, data)
+	}
+	if mapEntry {
+		message = descriptorLen(message, 7, string(descriptorVarint(nil, 7, 1)))
+	}
+	return string(message)
+}
+
+// Function to write the descriptor set of a proto3 file with a message type of every field kind
+func writeTestDescriptor(t *testing.T) string {
+	t.Helper()
+	flagsEntry := descriptorMessage("FlagsEntry", true, []string{
+		descriptorField("key", 1, 1, protoBool, ""), descriptorField("value", 2, 1, protoString, "")})
+	countsEntry := descriptorMessage("CountsEntry", true, []string{
+		descriptorField("key", 1, 1, protoInt32, ""), descriptorField("value", 2, 1, protoInt32, "")})
+	inner := descriptorMessage("Inner", false, []string{descriptorField("id", 1, 1, protoInt32, "")})
+	sample := descriptorMessage("Sample", false, []string{
+		descriptorField("id", 1, 1, protoInt32, ""),
+		descriptorField("name", 2, 1, protoString, ""),
+		descriptorField("values", 3, 3, protoInt32, ""),
+		string(descriptorLen([]byte(descriptorField("delta_value", 4, 1, protoSint32, "")), 10, "deltaValue")),
+		descriptorField("big", 5, 1, protoSint64, ""),
+		descriptorField("total", 6, 1, protoInt64, ""),
+		descriptorField("stamp", 7, 1, protoFixed64, ""),
+		descriptorField("color", 8, 1, protoEnum, ".test.Color"),
+		descriptorField("inner", 9, 1, protoMessage, ".test.Sample.Inner"),
+		descriptorField("flags", 10, 3, protoMessage, ".test.Sample.FlagsEntry"),
+		descriptorField("ratio", 11, 1, protoDouble, ""),
+		descriptorField("counts", 12, 3, protoMessage, ".test.Sample.CountsEntry"),
+	}, inner, flagsEntry, countsEntry)
+	color := descriptorLen(nil, 1, "Color")
+	for number, name := range []string{"RED", "GREEN", "BLUE"} {
+		value := descriptorVarint(descriptorLen(nil, 1, name), 2, uint64(number))
+		color = descriptorLen(color, 2, string(value))
+	}
+
+	file := descriptorLen(nil, 1, "test.proto")
+	file = descriptorLen(file, 2, "test")
+	file = descriptorLen(file, 4, sample)
+	file = descriptorLen(file, 5, string(color))
+	file = descriptorLen(file, 12, "proto3")
+	fileName := filepath.Join(t.TempDir(), "test.pb")
+	if err := os.WriteFile(fileName, descriptorLen(nil, 1, string(file)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+// Every field kind encodes to the bytes of the protobuf encoding specification
+func TestProtoBodyEncoding(t *testing.T) {
+	schema, err := loadProtoDescriptor(writeTestDescriptor(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		json     string
+		expected string
+	}{
+		{"varint", `{"id": 150}`, "089601"},
+		{"string", `{"name": "testing"}`, "120774657374696e67"},
+		{"packed", `{"values": [3, 270, 86942]}`, "1a06038e029ea705"},
+		{"zigzag 32-bit with JSON name", `{"deltaValue": -2}`, "2003"},
+		{"zigzag 64-bit", `{"big": "-9223372036854775808"}`, "28ffffffffffffffffff01"},
+		{"negative int64 string", `{"total": "-1"}`, "30ffffffffffffffffff01"},
+		{"fixed64 string", `{"stamp": "18446744073709551615"}`, "39ffffffffffffffff"},
+		{"enum name", `{"color": "BLUE"}`, "4002"},
+		{"enum number", `{"color": 1}`, "4001"},
+		{"nested", `{"inner": {"id": 1}}`, "4a020801"},
+		{"bool map", `{"flags": {"true": "on", "false": "off"}}`, "5207080012036f66665206080112026f6e"},
+		{"int32 map", `{"counts": {"7": 5}}`, "620408071005"},
+		{"double", `{"ratio": 1.5}`, "59000000000000f83f"},
+		{"field order", `{"name": "a", "id": 1}`, "0801120161"},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			_, data, err := newProtoBody(schema, ".test.Sample", test.json)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, _ := hex.DecodeString(test.expected)
+			if !bytes.Equal(data, expected) {
+				t.Errorf("Expected %s, got %x", test.expected, data)
+			}
+		})
+	}
+}
+
+// Values that do not fit the field type are errors, before the test starts
+func TestProtoBodyErrors(t *testing.T) {
+	schema, err := loadProtoDescriptor(writeTestDescriptor(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct{ json, expected string }{
+		{`{"id": 2147483648}`, "not a valid 32-bit integer"},
+		{`{"flags": {"yes": "on"}}`, "not true or false"},
+		{`{"color": "PURPLE"}`, "not a value of test.Color"},
+		{`{"missing": 1}`, "has no field \"missing\""},
+		{`{"values": 3}`, "expected a JSON array"},
+	} {
+		if _, _, err := newProtoBody(schema, "test.Sample", test.json); err == nil ||
+			!strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected an error with \"%s\", got %v", test.json, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Function to load the query strings from a -queryFile, one per line.  Blank lines and lines starting with "#" are
+// skipped.  A leading "?" is optional.
+func loadQueryFile(fileName string) ([]string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var queries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		queries = append(queries, strings.TrimPrefix(line, "?"))
+	}
+	return queries, scanner.Err()
+}
+
+// Function to get the round-robin index for a thread iteration.  Each thread starts at a different index so the
+// threads do not send the same value at the same time.
+func roundRobin(threadID int, iteration int, count int) int {
+	return (threadID + iteration) % count
+}
+
+// Function to join the base URL query with a query from the file
+func joinQuery(baseQuery string, query string) string {
+	if baseQuery == "" {
+		return query
+	}
+	return baseQuery + "&" + query
+}
+
+// Function to print the response time statistics for each query string in query order
+func printQueryStats(queryStats map[string]*LatencySummary) {
+	printKeyedStats("Query", queryStats, nil)
+}
+
+// Function to print the response time statistics, and the failures when failures is not nil, for each key in order
+func printKeyedStats(kind string, keyedStats map[string]*LatencySummary, failures map[string]int) {
+	keys := make([]string, 0, len(keyedStats))
+	for key := range keyedStats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		summary := keyedStats[key]
+		failed := ""
+		if failures != nil {
+			failed = fmt.Sprintf(" - Failed: %d", failures[key])
+		}
+		fmt.Printf("%s %s - Count: %d%s - Average: %.2f ms - Min: %.2f ms - Max: %.2f ms\n", kind, key, summary.Count,
+			failed, summary.AverageResponseTime, summary.MinResponseTime, summary.MaxResponseTime)
+	}
+}
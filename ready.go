@@ -0,0 +1,64 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Function to poll the -waitForReady URL every interval until it returns 200 or the timeout passes.  Uses its own
+// client so the polls are not counted as test connections.  Returns the wait time, or the last status or error.
+func waitForReady(ctx context.Context, url string, tlsConfig *tls.Config, requestTimeout time.Duration,
+	timeout time.Duration, interval time.Duration) (time.Duration, error) {
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true},
+		Timeout:   requestTimeout,
+	}
+	defer client.CloseIdleConnections()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastErr error
+	for {
+		err := pollReady(ctx, client, url)
+		if err == nil {
+			return time.Since(startTime), nil
+		}
+		// A poll cut off by the timeout says less than the one before it
+		if ctx.Err() == nil || lastErr == nil {
+			lastErr = err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return time.Since(startTime), lastErr
+		}
+	}
+}
+
+// Function to send a single readiness request.  Returns nil for a 200 response.
+func pollReady(ctx context.Context, client *http.Client, url string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
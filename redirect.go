@@ -0,0 +1,48 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Default redirect limit, 10 redirects.  That is one more than the Go http.Client default, which stops after 10
+// requests.
+const defaultMaxRedirects = 10
+
+// Error for a request that exceeded the redirect limit
+var errTooManyRedirects = errors.New("too many redirects")
+
+// Context key for the redirect chain recorded for a request
+type redirectChainKey struct{}
+
+// Function to attach an empty redirect chain to the request context
+func withRedirectChain(request *http.Request, chain *[]string) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), redirectChainKey{}, chain))
+}
+
+// Function to create the http.Client CheckRedirect function.  Records each hop in the request redirect chain and
+// fails the redirect past the limit, so a limit of 0 fails every redirect.
+func redirectPolicy(maxRedirects int) func(*http.Request, []*http.Request) error {
+	return func(request *http.Request, via []*http.Request) error {
+		if chain, ok := request.Context().Value(redirectChainKey{}).(*[]string); ok {
+			*chain = append(*chain, request.URL.String())
+		}
+		// The via requests are the original request and the redirects followed so far
+		if len(via) > maxRedirects {
+			return fmt.Errorf("%w, stopped after %d", errTooManyRedirects, maxRedirects)
+		}
+		return nil
+	}
+}
+
+// Function to print the redirect hops of a request.  The caller must hold the output mutex.
+func printRedirects(result *requestResult) {
+	for i, hop := range result.Redirects {
+		fmt.Printf("Thread %2d.%-6d -   Redirect %d: %s\n", result.ThreadID, result.Iteration, i+1, hop)
+	}
+}
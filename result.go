@@ -0,0 +1,490 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Result of a single request.  The fields are shared by the per-request CSV and JSON outputs.
+type requestResult struct {
+	ThreadID  int `json:"thread"`
+	Iteration int `json:"iteration"`
+	// Position of the request in its -burst starting at 1, zero without -burst
+	Burst int `json:"burst,omitempty"`
+	// Number of the concurrent -streams request in the iteration, zero without -streams
+	Stream int `json:"stream,omitempty"`
+	// Step of the -chainURL flow, 1 for the first request and 2 for the chained one, zero without -chainURL
+	Step int    `json:"step,omitempty"`
+	URL  string `json:"url"`
+	// Unique -correlationId request ID sent in the correlation header
+	CorrelationID string `json:"correlationId,omitempty"`
+	// -label run label
+	Label string `json:"label,omitempty"`
+	// Remote address of the -hostsFile backend that served the final attempt
+	Backend string `json:"backend,omitempty"`
+	// HTTP status code of the final attempt, zero when there was no response
+	StatusCode int `json:"statusCode"`
+	// Response time of the final attempt in milliseconds
+	ResponseTime float64 `json:"responseTimeMs"`
+	Retries      int     `json:"retries"`
+	// Response body bytes read from the final attempt
+	Bytes int64 `json:"bytes"`
+	// Milliseconds from sending the final attempt to finishing the -readRate body read
+	HoldTime float64 `json:"holdTimeMs,omitempty"`
+	// Size of the -bodySizeMin to -bodySizeMax filler body sent
+	RequestBytes int `json:"requestBytes,omitempty"`
+	randomBody   bool
+	// Response body was larger than -maxBodySize and was not read past the cap
+	Truncated bool `json:"truncated,omitempty"`
+	// URLs of the redirect hops followed by the final attempt
+	Redirects []string `json:"redirects,omitempty"`
+	// Query string from the -queryFile appended to the URL
+	Query string `json:"query,omitempty"`
+	// Method and URL of the -target file target
+	Target string `json:"target,omitempty"`
+	target *Target
+	// Milliseconds in each -trace phase of the final attempt
+	phases [phaseCount]float64
+	traced bool
+	// Response headers of the final attempt
+	header http.Header
+	// Response of the final attempt had "Connection: close"
+	serverClose bool
+	// Start time of the final attempt
+	startTime time.Time
+	// Function to release the per-request timeout of a target request, nil without one
+	cancel context.CancelFunc
+	// Name of the -bodyDir file sent as the request body
+	BodyFile string `json:"bodyFile,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Request interrupted by the test stopping early
+	Cancelled bool `json:"cancelled,omitempty"`
+	err       error
+	// Response satisfied the success predicate
+	satisfied bool
+	// Request closed its connection for -rotateConnAfter
+	rotated bool
+	// Request failed before it got a connection, in the dial or the TLS handshake
+	connectFailed bool
+	// Request was sent again with a refreshed bearer token after a 401 response
+	authRetried bool
+	// Request was sent with "Expect: 100-continue", whether the server sent a "100 Continue", and the wait for it
+	expectContinue bool
+	got100         bool
+	continueWait   float64
+}
+
+// Function to release the resources of a prepared request after it is done
+func (result *requestResult) release() {
+	if result.cancel != nil {
+		result.cancel()
+	}
+}
+
+// Column names of the per-request CSV output in the order written by csvRecord
+var csvHeader = []string{"thread", "iteration", "url", "statusCode", "responseTimeMs", "retries", "redirects",
+	"bodyFile", "error", "correlationId", "label"}
+
+// Function to format the request result as a CSV row
+func (result *requestResult) csvRecord() []string {
+	return []string{
+		strconv.Itoa(result.ThreadID),
+		strconv.Itoa(result.Iteration),
+		result.URL,
+		strconv.Itoa(result.StatusCode),
+		strconv.FormatFloat(result.ResponseTime, 'f', 3, 64),
+		strconv.Itoa(result.Retries),
+		strings.Join(result.Redirects, " "),
+		result.BodyFile,
+		result.Error,
+		result.CorrelationID,
+		result.Label,
+	}
+}
+
+// Response time statistics for a single status code, query, or other group of requests
+type LatencySummary struct {
+	Count               int     `json:"count"`
+	AverageResponseTime float64 `json:"averageResponseTimeMs"`
+	MinResponseTime     float64 `json:"minResponseTimeMs"`
+	MaxResponseTime     float64 `json:"maxResponseTimeMs"`
+	totalResponseTime   float64
+}
+
+// Function to add a response time to the status code statistics
+func (summary *LatencySummary) add(responseTime float64) {
+	summary.Count++
+	summary.totalResponseTime += responseTime
+	summary.AverageResponseTime = summary.totalResponseTime / float64(summary.Count)
+	if summary.Count == 1 {
+		summary.MinResponseTime = responseTime
+	}
+	summary.MinResponseTime = min(summary.MinResponseTime, responseTime)
+	summary.MaxResponseTime = max(summary.MaxResponseTime, responseTime)
+}
+
+// Summary of a test run.  Written as JSON by -jsonOut.
+type Result struct {
+	// -label run label to tell apart the results of several load generators
+	Label               string  `json:"label,omitempty"`
+	URL                 string  `json:"url"`
+	Threads             int     `json:"threads"`
+	TotalRequests       int     `json:"totalRequests"`
+	TotalTime           float64 `json:"totalTimeSec"`
+	AverageResponseTime float64 `json:"averageResponseTimeMs"`
+	P50ResponseTime     float64 `json:"p50ResponseTimeMs"`
+	P90ResponseTime     float64 `json:"p90ResponseTimeMs"`
+	P95ResponseTime     float64 `json:"p95ResponseTimeMs"`
+	P99ResponseTime     float64 `json:"p99ResponseTimeMs"`
+	// Response time percentiles with too few samples to be meaningful
+	UnreliablePercentiles []string `json:"unreliablePercentiles,omitempty"`
+	RequestsPerSecond     float64  `json:"requestsPerSecond"`
+	// Response body size statistics in bytes
+	AverageResponseSize float64 `json:"averageResponseSizeBytes"`
+	MinResponseSize     float64 `json:"minResponseSizeBytes"`
+	MaxResponseSize     float64 `json:"maxResponseSizeBytes"`
+	P50ResponseSize     float64 `json:"p50ResponseSizeBytes"`
+	P95ResponseSize     float64 `json:"p95ResponseSizeBytes"`
+	P99ResponseSize     float64 `json:"p99ResponseSizeBytes"`
+	// Time and throughput after the ramp-up and warmup until the first thread finished
+	SteadyStateTime              float64 `json:"steadyStateTimeSec"`
+	SteadyStateRequestsPerSecond float64 `json:"steadyStateRequestsPerSecond"`
+	FailedRequests               int     `json:"failedRequests"`
+	// Success predicate and the number of requests that satisfied it
+	SuccessPredicate  string `json:"successPredicate,omitempty"`
+	SatisfiedRequests int    `json:"satisfiedRequests"`
+	CancelledRequests int    `json:"cancelledRequests"`
+	InvalidJSON       int    `json:"invalidJson"`
+	// Total seconds and the average and maximum milliseconds that the -readRate requests held their connections
+	TotalHoldTime   float64 `json:"totalHoldTimeSec,omitempty"`
+	AverageHoldTime float64 `json:"averageHoldTimeMs,omitempty"`
+	MaxHoldTime     float64 `json:"maxHoldTimeMs,omitempty"`
+	// Number of responses that failed an -expectHeader check
+	HeaderCheckFailures int `json:"headerCheckFailures,omitempty"`
+	// Number of responses that failed an -assertJSON check
+	JSONAssertionFailures int `json:"jsonAssertionFailures,omitempty"`
+	// Number of responses without the -verifyEcho response header and with the value of another request
+	EchoMissing    int `json:"echoMissing,omitempty"`
+	EchoMismatches int `json:"echoMismatches,omitempty"`
+	// Number of -validatorCommand runs, their average time in milliseconds, and the responses it rejected
+	ValidatorRuns        int     `json:"validatorRuns,omitempty"`
+	AverageValidatorTime float64 `json:"averageValidatorTimeMs,omitempty"`
+	ValidatorFailures    int     `json:"validatorFailures,omitempty"`
+	TruncatedResponses   int     `json:"truncatedResponses"`
+	RedirectedRequests   int     `json:"redirectedRequests"`
+	TooManyRedirects     int     `json:"tooManyRedirects"`
+	FirstTrySuccesses    int     `json:"firstTrySuccesses"`
+	RetriedSuccesses     int     `json:"retriedSuccesses"`
+	Retries              int     `json:"retries"`
+	ConnectionsOpened    int64   `json:"connectionsOpened"`
+	IPv4Connections      int64   `json:"ipv4Connections"`
+	IPv6Connections      int64   `json:"ipv6Connections"`
+	// DNS lookups made while dialing, and the -dnsCache hits and hit rate in percent
+	DNSLookups      int64   `json:"dnsLookups"`
+	DNSCached       bool    `json:"-"`
+	DNSCacheHits    int64   `json:"dnsCacheHits,omitempty"`
+	DNSCacheHitRate float64 `json:"dnsCacheHitRate,omitempty"`
+	PeakConcurrency int64   `json:"peakConcurrency"`
+	// The -maxRPSPerThread cap and the requests per second the threads achieved
+	MaxRPSPerThread  float64 `json:"maxRPSPerThread,omitempty"`
+	AverageThreadRPS float64 `json:"averageThreadRPS,omitempty"`
+	MinThreadRPS     float64 `json:"minThreadRPS,omitempty"`
+	MaxThreadRPS     float64 `json:"maxThreadRPS,omitempty"`
+	// Number of -jitterClock waits between the requests, their average and maximum delay in waking up in
+	// milliseconds, and the average delay of the first and second half of the waits of each thread
+	ScheduledWaits            int     `json:"scheduledWaits,omitempty"`
+	AverageSchedulingDelay    float64 `json:"averageSchedulingDelayMs,omitempty"`
+	MaxSchedulingDelay        float64 `json:"maxSchedulingDelayMs,omitempty"`
+	FirstHalfSchedulingDelay  float64 `json:"firstHalfSchedulingDelayMs,omitempty"`
+	SecondHalfSchedulingDelay float64 `json:"secondHalfSchedulingDelayMs,omitempty"`
+	// The -latencyTarget in milliseconds, the concurrency of each control interval, and the average throughput and
+	// highest concurrency of the intervals that met the target
+	LatencyTarget              float64        `json:"latencyTargetMs,omitempty"`
+	ConcurrencyTimeline        []adaptiveStep `json:"concurrencyTimeline,omitempty"`
+	SustainedRequestsPerSecond float64        `json:"sustainedRequestsPerSecond,omitempty"`
+	KneeConcurrency            int            `json:"kneeConcurrency,omitempty"`
+	// The -maxInflight cap on the concurrent requests, zero for no cap
+	MaxInflight int `json:"maxInflight,omitempty"`
+	// Sizes of the -bodySizeMin to -bodySizeMax request bodies and the correlation of the size with the response
+	// time from -1 to 1
+	AverageRequestBodySize     float64 `json:"averageRequestBodySizeBytes,omitempty"`
+	MinRequestBodySize         float64 `json:"minRequestBodySizeBytes,omitempty"`
+	MaxRequestBodySize         float64 `json:"maxRequestBodySizeBytes,omitempty"`
+	BodySizeLatencyCorrelation float64 `json:"bodySizeLatencyCorrelation,omitempty"`
+	// Requests completed in each second of the test for -rpsTimeline
+	RequestsPerSecondTimeline []int `json:"requestsPerSecondTimeline,omitempty"`
+	// Number of requests that timed out waiting for the response headers past the -responseHeaderTimeout
+	ResponseHeaderTimeouts int `json:"responseHeaderTimeouts,omitempty"`
+	// Failed requests that never connected because the dial or the TLS handshake failed, and the failed requests
+	// that connected, to tell a server refusing connections from one failing requests
+	ConnectionFailures    int `json:"connectionFailures,omitempty"`
+	FailedAfterConnecting int `json:"failedAfterConnecting,omitempty"`
+	// Number of requests that failed because the client ran out of local ports or addresses
+	LocalPortFailures int `json:"localPortFailures,omitempty"`
+	// Number of requests that closed their connection for -rotateConnAfter
+	ConnectionRotations int `json:"connectionRotations,omitempty"`
+	// The -requestsPerConn limit, the connections closed at it, the requests at it that the server answered with
+	// "Connection: close" or that failed, and the connections the server closed before it with the most requests
+	// one of them served
+	RequestsPerConnection        int `json:"requestsPerConnection,omitempty"`
+	ConnectionsLimited           int `json:"connectionsLimited,omitempty"`
+	ServerClosedAtLimit          int `json:"serverClosedAtLimit,omitempty"`
+	FailedAtLimit                int `json:"failedAtLimit,omitempty"`
+	ServerClosedBeforeLimit      int `json:"serverClosedBeforeLimit,omitempty"`
+	MaxRequestsBeforeServerClose int `json:"maxRequestsBeforeServerClose,omitempty"`
+	// The -cacheHeader, the responses it marked as hits and misses, the responses without it, and the hit percentage
+	CacheHeader        string  `json:"cacheHeader,omitempty"`
+	CacheHits          int     `json:"cacheHits,omitempty"`
+	CacheMisses        int     `json:"cacheMisses,omitempty"`
+	WithoutCacheHeader int     `json:"withoutCacheHeader,omitempty"`
+	CacheHitRate       float64 `json:"cacheHitRatePercent,omitempty"`
+	// Response time statistics of the two -chainURL steps and the chained requests not sent for a failed first
+	// request or a missing value
+	ChainSteps   []ChainStep `json:"chainSteps,omitempty"`
+	ChainSkipped int         `json:"chainSkipped,omitempty"`
+	// Number of -expect100 requests, the ones the server sent a "100 Continue" for, and their average wait for it
+	ExpectContinueRequests int     `json:"expectContinueRequests,omitempty"`
+	Got100Continue         int     `json:"got100Continue,omitempty"`
+	AverageContinueWait    float64 `json:"averageContinueWaitMs,omitempty"`
+	// The -connectionLifetime in seconds and the connections closed for their age
+	ConnectionLifetime  float64 `json:"connectionLifetimeSec,omitempty"`
+	ConnectionsRecycled int     `json:"connectionsRecycled,omitempty"`
+	// Number of times -rebuildOnErrors replaced the HTTP client transport
+	ClientRebuilds int64 `json:"clientRebuilds,omitempty"`
+	// TLS handshakes, the ones that resumed a session, and the average handshake time in milliseconds
+	TLSHandshakes           int64   `json:"tlsHandshakes,omitempty"`
+	TLSResumedHandshakes    int64   `json:"tlsResumedHandshakes,omitempty"`
+	AverageTLSHandshakeTime float64 `json:"averageTlsHandshakeTimeMs,omitempty"`
+	// Garbage collections, total GC pause time in milliseconds, and peak heap of the tester with -runtimeStats
+	GCCount       uint32  `json:"gcCount,omitempty"`
+	GCPauseTime   float64 `json:"gcPauseTimeMs,omitempty"`
+	PeakHeapBytes uint64  `json:"peakHeapBytes,omitempty"`
+	// Number of -replayTiming requests sent on the schedule and the average and maximum milliseconds they were sent
+	// after their captured times
+	ReplayedRequests int `json:"replayedRequests,omitempty"`
+	// Number of times the -replayLoop list was started
+	ReplayLoops             int     `json:"replayLoops,omitempty"`
+	AverageScheduleLateness float64 `json:"averageScheduleLatenessMs,omitempty"`
+	MaxScheduleLateness     float64 `json:"maxScheduleLatenessMs,omitempty"`
+	// Time in milliseconds to open the -prewarm connections before the test and the number opened
+	PrewarmTime          float64 `json:"prewarmTimeMs,omitempty"`
+	PrewarmedConnections int     `json:"prewarmedConnections,omitempty"`
+	// Apdex score from 0 to 1 for the -apdexTarget in milliseconds, omitted when not set
+	ApdexTarget float64 `json:"apdexTargetMs,omitempty"`
+	Apdex       float64 `json:"apdex,omitempty"`
+	// Number of times the bearer token was refreshed after the first fetch
+	TokenRefreshes int `json:"tokenRefreshes,omitempty"`
+	// Number of -preRequestCommand runs, their average time in milliseconds, and the requests that reused an output
+	PreRequestRuns        int     `json:"preRequestRuns,omitempty"`
+	AveragePreRequestTime float64 `json:"averagePreRequestTimeMs,omitempty"`
+	PreRequestCacheHits   int     `json:"preRequestCacheHits,omitempty"`
+	// Number of requests sent again with the refreshed token after a 401 response
+	AuthRetries int `json:"authRetries,omitempty"`
+	// Test stopped early by Ctrl-C
+	Interrupted bool `json:"interrupted"`
+	// Reason the test stopped early on its own, like an SLA alert
+	AbortReason string `json:"abortReason,omitempty"`
+	// Number of times the rolling p99 crossed the -slaAlert threshold
+	SLAAlerts int `json:"slaAlerts,omitempty"`
+	// Outcome of each -slo check
+	SLOs []SLOResult `json:"slos,omitempty"`
+	// Response time statistics keyed by status code, "0" for requests that failed without a response
+	StatusCodes map[string]*LatencySummary `json:"statusCodes"`
+}
+
+// Outcome of a service level objective check
+type SLOResult struct {
+	// Name of the -slaFile SLA, omitted for a -slo flag
+	Name   string  `json:"name,omitempty"`
+	SLO    string  `json:"slo"`
+	Passed bool    `json:"passed"`
+	Actual float64 `json:"actual"`
+	// Unit of the actual value, "ms" or "%"
+	Unit string `json:"unit"`
+	// URL or path prefix of the requests a scoped -slaFile SLA is checked against and their number
+	Scope    string `json:"scope,omitempty"`
+	Requests int    `json:"requests,omitempty"`
+}
+
+// Function to check whether all the SLOs passed.  Returns true when there are no SLOs.
+func (result *Result) SLOsPassed() bool {
+	for _, slo := range result.SLOs {
+		if !slo.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// Function to build the test summary from the collected statistics.  The threads must be finished.
+func (stats *testStats) summarize(url string, numThreads int, totalTime float64) Result {
+	result := Result{
+		Label:              stats.label,
+		URL:                url,
+		Threads:            numThreads,
+		TotalRequests:      len(stats.responseTimes),
+		TotalTime:          totalTime,
+		FailedRequests:     stats.failures,
+		CancelledRequests:  stats.cancelled,
+		InvalidJSON:        stats.invalidJSON,
+		TruncatedResponses: stats.truncated,
+		RedirectedRequests: stats.redirected,
+		TooManyRedirects:   stats.tooManyRedirects,
+		FirstTrySuccesses:  stats.firstTrySuccesses,
+		RetriedSuccesses:   stats.retriedSuccesses,
+		Retries:            stats.retries,
+		PeakConcurrency:    stats.peakInFlight.Load(),
+		MaxInflight:        cap(stats.inflightSlots),
+		StatusCodes:        make(map[string]*LatencySummary),
+	}
+
+	// Calculate the average requests per second over the whole test and over the steady-state window
+	if totalTime > 0 {
+		result.RequestsPerSecond = float64(result.TotalRequests) / totalTime
+	}
+	result.SteadyStateTime, result.SteadyStateRequestsPerSecond = stats.steadyState()
+
+	// Calculate the average response time
+	var totalResponseTime float64
+	for _, rt := range stats.responseTimes {
+		totalResponseTime += rt
+	}
+	if result.TotalRequests > 0 {
+		result.AverageResponseTime = totalResponseTime / float64(result.TotalRequests)
+	}
+
+	// Calculate the response time percentiles
+	sorted := append([]float64(nil), stats.responseTimes...)
+	sort.Float64s(sorted)
+	stats.sortedTimes = sorted
+	result.P50ResponseTime = percentile(sorted, 50)
+	result.P90ResponseTime = percentile(sorted, 90)
+	result.P95ResponseTime = percentile(sorted, 95)
+	result.P99ResponseTime = percentile(sorted, 99)
+	for _, p := range []float64{50, 90, 95, 99} {
+		if len(sorted) > 0 && !percentileSupported(len(sorted), p) {
+			result.UnreliablePercentiles = append(result.UnreliablePercentiles, fmt.Sprintf("p%.0f", p))
+		}
+	}
+
+	// Calculate the response size statistics
+	sizes := append([]float64(nil), stats.responseSizes...)
+	sort.Float64s(sizes)
+	if len(sizes) > 0 {
+		var totalSize float64
+		for _, size := range sizes {
+			totalSize += size
+		}
+		result.AverageResponseSize = totalSize / float64(len(sizes))
+		result.MinResponseSize = sizes[0]
+		result.MaxResponseSize = sizes[len(sizes)-1]
+	}
+	result.P50ResponseSize = percentile(sizes, 50)
+	result.P95ResponseSize = percentile(sizes, 95)
+	result.P99ResponseSize = percentile(sizes, 99)
+
+	// Calculate the request body size statistics
+	if len(stats.bodySizes) > 0 {
+		sizes := make([]float64, len(stats.bodySizes))
+		times := make([]float64, len(stats.bodySizes))
+		result.MinRequestBodySize = stats.bodySizes[0].size
+		for i, sample := range stats.bodySizes {
+			sizes[i], times[i] = sample.size, sample.responseTime
+			result.AverageRequestBodySize += sample.size
+			result.MinRequestBodySize = min(result.MinRequestBodySize, sample.size)
+			result.MaxRequestBodySize = max(result.MaxRequestBodySize, sample.size)
+		}
+		result.AverageRequestBodySize /= float64(len(sizes))
+		result.BodySizeLatencyCorrelation = correlation(sizes, times)
+	}
+
+	for code, summary := range stats.statusCodes {
+		result.StatusCodes[strconv.Itoa(code)] = summary
+	}
+	return result
+}
+
+// Function to calculate the Apdex score, (satisfied + tolerating / 2) / total, where satisfied responses are within
+// the target and tolerating responses are within four times the target
+func apdexScore(responseTimes []float64, target float64) float64 {
+	if len(responseTimes) == 0 {
+		return 0
+	}
+	satisfied, tolerating := 0, 0
+	for _, rt := range responseTimes {
+		if rt <= target {
+			satisfied++
+		} else if rt <= 4*target {
+			tolerating++
+		}
+	}
+	return (float64(satisfied) + float64(tolerating)/2) / float64(len(responseTimes))
+}
+
+// Function to get the nearest-rank percentile from sorted values.  Returns zero when there are no values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+// Function to check if there are enough samples for a percentile to mean anything.  The heuristic needs at least
+// one sample above the percentile, so p99 needs 100 samples and p95 needs 20.
+func percentileSupported(count int, p float64) bool {
+	return float64(count)*(100-p)/100 >= 1
+}
+
+// Function to print the response time statistics for each status code in code order
+func printStatusCodes(result *Result) {
+	codes := make([]int, 0, len(result.StatusCodes))
+	for code := range result.StatusCodes {
+		number, _ := strconv.Atoi(code)
+		codes = append(codes, number)
+	}
+	sort.Ints(codes)
+
+	for _, code := range codes {
+		summary := result.StatusCodes[strconv.Itoa(code)]
+		name := http.StatusText(code)
+		if code == 0 {
+			name = "No response"
+		}
+		fmt.Printf("Status %3d %-20s - Count: %d - Average: %.2f ms - Min: %.2f ms - Max: %.2f ms\n", code, name,
+			summary.Count, summary.AverageResponseTime, summary.MinResponseTime, summary.MaxResponseTime)
+	}
+}
+
+// Function to print the failed request count for each -bodyDir file that caused a failure
+func printBodyFileFailures(failures map[string]int) {
+	names := make([]string, 0, len(failures))
+	for name := range failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("Body file %s - Failed requests: %d\n", name, failures[name])
+	}
+}
+
+// Function to write a value to a file as indented JSON
+func writeJSON(fileName string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, append(data, '\n'), 0644)
+}
@@ -0,0 +1,43 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Function to calculate the wait time before the next retry.  Attempt is zero for the first retry.
+func retryDelay(cfg *testConfig, attempt int) time.Duration {
+	if cfg.retryPolicy != "exponential" {
+		return cfg.retryBackoff
+	}
+
+	// AWS-style full jitter: sleep = random_between(0, min(cap, base * 2^attempt)).
+	// Spreading the wait across the whole window keeps the threads from retrying in synchronized storms.
+	ceiling := cfg.retryMaxBackoff
+	if attempt < 32 {
+		if backoff := cfg.retryBackoff << attempt; backoff > 0 && backoff < ceiling {
+			ceiling = backoff
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
+
+// Function to wait for the retry delay, or until the request context ends, like when the test stops.  Returns false
+// when the context ended first.
+func waitRetry(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,98 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Fixed-size ring buffer of the most recent response times
+type rollingWindow struct {
+	samples []float64
+	next    int
+	full    bool
+	// Reused buffer for sorting the samples
+	sorted []float64
+}
+
+// Function to create a rolling window holding the last size samples
+func newRollingWindow(size int) *rollingWindow {
+	return &rollingWindow{samples: make([]float64, size), sorted: make([]float64, 0, size)}
+}
+
+// Function to add a sample, replacing the oldest sample when the window is full
+func (window *rollingWindow) add(sample float64) {
+	window.samples[window.next] = sample
+	window.next++
+	if window.next == len(window.samples) {
+		window.next = 0
+		window.full = true
+	}
+}
+
+// Function to get the number of samples in the window
+func (window *rollingWindow) count() int {
+	if window.full {
+		return len(window.samples)
+	}
+	return window.next
+}
+
+// Function to get a percentile of the samples in the window
+func (window *rollingWindow) percentile(p float64) float64 {
+	window.sorted = append(window.sorted[:0], window.samples[:window.count()]...)
+	sort.Float64s(window.sorted)
+	return percentile(window.sorted, p)
+}
+
+// Inline alert when the rolling p99 response time is over the SLA threshold.  Guarded by the output mutex.
+type slaAlert struct {
+	window *rollingWindow
+	// Threshold in milliseconds
+	threshold float64
+	// Function to stop the test on an alert, nil to keep running
+	abort func(error)
+	// Number of samples between checks, so the window is not sorted for every request
+	checkEvery int
+	sinceCheck int
+	alerting   bool
+	alerts     int
+}
+
+// Function to create an SLA alert over the last windowSize response times
+func newSLAAlert(threshold float64, windowSize int, abort func(error)) *slaAlert {
+	return &slaAlert{
+		window:     newRollingWindow(windowSize),
+		threshold:  threshold,
+		abort:      abort,
+		checkEvery: max(1, windowSize/10),
+	}
+}
+
+// Function to add a response time and print an alert line when the rolling p99 crosses the threshold.  The caller
+// must hold the output mutex.
+func (alert *slaAlert) add(responseTime float64) {
+	alert.window.add(responseTime)
+	alert.sinceCheck++
+	if alert.sinceCheck < alert.checkEvery {
+		return
+	}
+	alert.sinceCheck = 0
+
+	p99 := alert.window.percentile(99)
+	if p99 > alert.threshold && !alert.alerting {
+		alert.alerting = true
+		alert.alerts++
+		fmt.Printf("ALERT: Rolling p99 response time %.2f ms over the last %d requests exceeds %.2f ms\n", p99,
+			alert.window.count(), alert.threshold)
+		if alert.abort != nil {
+			alert.abort(fmt.Errorf("rolling p99 response time %.2f ms exceeded %.2f ms", p99, alert.threshold))
+		}
+	} else if p99 <= alert.threshold && alert.alerting {
+		alert.alerting = false
+		fmt.Printf("ALERT cleared: Rolling p99 response time %.2f ms over the last %d requests is within %.2f ms\n",
+			p99, alert.window.count(), alert.threshold)
+	}
+}
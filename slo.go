@@ -0,0 +1,269 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Service level objective from a -slo flag like "p99<200ms", "avg<=50ms", or "errors<1%"
+type sloCheck struct {
+	text string
+	// Name of the -slaFile SLA the check belongs to, empty for a -slo flag
+	name string
+	// Requests of the endpoint the SLA is scoped to, shared by the checks of the SLA.  Nil for the whole test.
+	scope *slaScope
+	// "p" for a percentile, "avg", "max", or "errors"
+	metric     string
+	percentile float64
+	// "<" or "<="
+	operator string
+	// Limit in milliseconds, or percent for the errors metric
+	limit float64
+}
+
+// Function to parse an SLO expression
+func parseSLO(text string) (sloCheck, error) {
+	check := sloCheck{text: text}
+	expression := strings.ReplaceAll(text, " ", "")
+
+	index := strings.IndexAny(expression, "<")
+	if index <= 0 {
+		return check, fmt.Errorf("missing \"<\" or \"<=\" in \"%s\"", text)
+	}
+	name, value := strings.ToLower(expression[:index]), expression[index+1:]
+	check.operator = "<"
+	if strings.HasPrefix(value, "=") {
+		check.operator = "<="
+		value = value[1:]
+	}
+
+	switch {
+	case name == "avg" || name == "max":
+		check.metric = name
+	case name == "errors" || name == "error" || name == "errorrate":
+		check.metric = "errors"
+	case strings.HasPrefix(name, "p"):
+		p, err := strconv.ParseFloat(name[1:], 64)
+		if err != nil || p <= 0 || p > 100 {
+			return check, fmt.Errorf("\"%s\" is not a valid percentile", name)
+		}
+		check.metric = "p"
+		check.percentile = p
+	default:
+		return check, fmt.Errorf("\"%s\" is not a valid SLO metric", name)
+	}
+
+	if check.metric == "errors" {
+		limit, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return check, fmt.Errorf("\"%s\" is not a valid percentage", value)
+		}
+		check.limit = limit
+		return check, nil
+	}
+
+	// A plain number is in milliseconds
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		value += "ms"
+	}
+	limit, err := time.ParseDuration(value)
+	if err != nil {
+		return check, fmt.Errorf("\"%s\" is not a valid duration", value)
+	}
+	check.limit = milliseconds(limit)
+	return check, nil
+}
+
+// Requests of the endpoint a -slaFile SLA is scoped to with "url=", like "checkout url=/checkout p99<300ms"
+type slaScope struct {
+	// URL prefix, or the path prefix when it starts with "/"
+	url           string
+	responseTimes []float64
+	failures      int
+}
+
+// Function to check whether a request URL is in the scope.  A path prefix is matched against the path of the request
+// URL without the scheme, host, and query.
+func (scope *slaScope) matches(requestURL string) bool {
+	if !strings.HasPrefix(scope.url, "/") {
+		return strings.HasPrefix(requestURL, scope.url)
+	}
+	path := requestURL
+	if _, afterScheme, found := strings.Cut(path, "://"); found {
+		path = afterScheme
+		if slash := strings.IndexByte(path, '/'); slash >= 0 {
+			path = path[slash:]
+		} else {
+			path = "/"
+		}
+	}
+	path, _, _ = strings.Cut(path, "?")
+	return strings.HasPrefix(path, scope.url)
+}
+
+// Function to add a request to the scope.  The caller must hold the output mutex.
+func (scope *slaScope) add(result *requestResult) {
+	scope.responseTimes = append(scope.responseTimes, result.ResponseTime)
+	if result.err != nil {
+		scope.failures++
+	}
+}
+
+// Function to get the distinct scopes of the checks, the ones the requests are added to
+func slaScopes(checks []sloCheck) []*slaScope {
+	var scopes []*slaScope
+	for i := range checks {
+		if checks[i].scope != nil && (len(scopes) == 0 || scopes[len(scopes)-1] != checks[i].scope) {
+			scopes = append(scopes, checks[i].scope)
+		}
+	}
+	return scopes
+}
+
+// Function to load a -slaFile.  Each line is a name, an optional "url=" scope, and comma-separated SLO expressions,
+// like "checkout url=/checkout p99 < 300ms, errors < 1%".  An SLA with a scope is checked against only the requests
+// with the URL or path prefix, and the others against the whole test.  Blank lines and lines starting with "#" are
+// skipped.
+func loadSLAFile(fileName string) ([]sloCheck, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var checks []sloCheck
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, expressions, found := strings.Cut(line, " ")
+		expressions = strings.TrimSpace(expressions)
+		var scope *slaScope
+		if strings.HasPrefix(expressions, "url=") {
+			var scopeURL string
+			scopeURL, expressions, _ = strings.Cut(expressions, " ")
+			if scopeURL == "url=" {
+				return nil, fmt.Errorf("line %d: empty \"url=\" scope", lineNumber)
+			}
+			scope = &slaScope{url: strings.TrimPrefix(scopeURL, "url=")}
+		}
+		if !found || strings.TrimSpace(expressions) == "" {
+			return nil, fmt.Errorf("line %d: expected \"name [url=prefix] SLO, SLO...\" but found \"%s\"",
+				lineNumber, line)
+		}
+		for _, expression := range strings.Split(expressions, ",") {
+			check, err := parseSLO(strings.TrimSpace(expression))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			check.name = strings.TrimSuffix(name, ":")
+			check.scope = scope
+			checks = append(checks, check)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("no SLAs")
+	}
+	return checks, nil
+}
+
+// Function to get the measured value of the SLO metric from the sorted response times and the test summary
+func (check *sloCheck) measure(sorted []float64, result *Result) float64 {
+	switch check.metric {
+	case "avg":
+		return result.AverageResponseTime
+	case "max":
+		if len(sorted) == 0 {
+			return 0
+		}
+		return sorted[len(sorted)-1]
+	case "errors":
+		if result.TotalRequests == 0 {
+			return 0
+		}
+		return float64(result.FailedRequests) * 100 / float64(result.TotalRequests)
+	}
+	return percentile(sorted, check.percentile)
+}
+
+// Function to check whether the measured value meets the SLO
+func (check *sloCheck) passed(actual float64) bool {
+	if check.operator == "<=" {
+		return actual <= check.limit
+	}
+	return actual < check.limit
+}
+
+// Function to check each SLO against the sorted response times and the test summary, or against the requests of its
+// scope.  A scoped SLA without any requests fails, since there is nothing to meet it.
+func evaluateSLOs(checks []sloCheck, sorted []float64, result *Result) []SLOResult {
+	results := make([]SLOResult, 0, len(checks))
+	for i := range checks {
+		checkSorted, checkResult := sorted, result
+		if scope := checks[i].scope; scope != nil {
+			sort.Float64s(scope.responseTimes)
+			checkSorted = scope.responseTimes
+			checkResult = &Result{TotalRequests: len(scope.responseTimes), FailedRequests: scope.failures}
+			for _, rt := range scope.responseTimes {
+				checkResult.AverageResponseTime += rt
+			}
+			if checkResult.TotalRequests > 0 {
+				checkResult.AverageResponseTime /= float64(checkResult.TotalRequests)
+			}
+		}
+		actual := checks[i].measure(checkSorted, checkResult)
+		unit := "ms"
+		if checks[i].metric == "errors" {
+			unit = "%"
+		}
+		slo := SLOResult{Name: checks[i].name, SLO: checks[i].text, Passed: checks[i].passed(actual), Actual: actual,
+			Unit: unit}
+		if checks[i].scope != nil {
+			slo.Scope = checks[i].scope.url
+			slo.Requests = checkResult.TotalRequests
+			slo.Passed = slo.Passed && slo.Requests > 0
+		}
+		results = append(results, slo)
+	}
+	return results
+}
+
+// Function to print PASS or FAIL with the measured value for each SLO, and the -slaFile SLAs as a table
+func printSLOs(results []SLOResult) {
+	nameWidth, printedHeader := 0, false
+	for _, slo := range results {
+		nameWidth = max(nameWidth, len(slo.Name))
+	}
+	for _, slo := range results {
+		outcome := "PASS"
+		if !slo.Passed {
+			outcome = "FAIL"
+		}
+		if slo.Name == "" {
+			fmt.Printf("SLO %-20s - %s - Actual: %.2f %s\n", slo.SLO, outcome, slo.Actual, slo.Unit)
+			continue
+		}
+		if !printedHeader {
+			fmt.Printf("%-*s  %-20s  %-6s  %s\n", nameWidth, "SLA", "Objective", "Result", "Actual")
+			printedHeader = true
+		}
+		if slo.Scope != "" && slo.Requests == 0 {
+			fmt.Printf("%-*s  %-20s  %-6s  No requests to %s\n", nameWidth, slo.Name, slo.SLO, outcome, slo.Scope)
+			continue
+		}
+		fmt.Printf("%-*s  %-20s  %-6s  %.2f %s\n", nameWidth, slo.Name, slo.SLO, outcome, slo.Actual, slo.Unit)
+	}
+}
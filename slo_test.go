@@ -0,0 +1,76 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A -slaFile SLA with a url= scope is checked against only its requests, and the others against the whole test
+func TestScopedSLAs(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sla.txt")
+	data := "all p99<100ms, errors<50%\ncheckout url=/checkout p99<10ms, errors<1%\nsearch url=/search avg<10ms\n"
+	if err := os.WriteFile(fileName, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checks, err := loadSLAFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := &testStats{slaScopes: slaScopes(checks)}
+	if len(stats.slaScopes) != 2 {
+		t.Fatalf("Expected 2 scopes, got %d", len(stats.slaScopes))
+	}
+	stats.record(&requestResult{URL: "http://x/checkout?id=1", ResponseTime: 5})
+	stats.record(&requestResult{URL: "http://x/checkout/pay", ResponseTime: 8})
+	stats.record(&requestResult{URL: "http://x/cart", ResponseTime: 50, err: errors.New("status 500")})
+	result := stats.summarize("http://x", 1, 1)
+
+	expected := []struct {
+		name     string
+		passed   bool
+		requests int
+	}{
+		{"all", true, 0},
+		{"all", true, 0},
+		{"checkout", true, 2},
+		{"checkout", true, 2},
+		{"search", false, 0},
+	}
+	slos := evaluateSLOs(checks, stats.sortedTimes, &result)
+	if len(slos) != len(expected) {
+		t.Fatalf("Expected %d SLO results, got %d", len(expected), len(slos))
+	}
+	for i, slo := range slos {
+		if slo.Name != expected[i].name || slo.Passed != expected[i].passed || slo.Requests != expected[i].requests {
+			t.Errorf("SLO %d: expected %+v, got %+v", i, expected[i], slo)
+		}
+	}
+}
+
+// The url= scope matches the path prefix without the query, or the full URL prefix
+func TestSLAScopeMatches(t *testing.T) {
+	cases := []struct {
+		scope   string
+		url     string
+		matches bool
+	}{
+		{"/checkout", "http://x/checkout", true},
+		{"/checkout", "https://x:8443/checkout/pay?id=1", true},
+		{"/checkout", "http://x/cart?next=/checkout", false},
+		{"/", "http://x", true},
+		{"http://x/a", "http://x/a/b", true},
+		{"http://x/a", "https://x/a", false},
+	}
+	for _, test := range cases {
+		scope := &slaScope{url: test.scope}
+		if matches := scope.matches(test.url); matches != test.matches {
+			t.Errorf("Scope %s and URL %s: expected %v, got %v", test.scope, test.url, test.matches, matches)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Function to copy the statistics a -summaryInterval block prints, so the sorting runs without the output mutex.  The
+// caller must hold the output mutex.
+func (stats *testStats) snapshot() *testStats {
+	snapshot := &testStats{
+		label:             stats.label,
+		responseTimes:     slices.Clone(stats.responseTimes),
+		responseSizes:     slices.Clone(stats.responseSizes),
+		failures:          stats.failures,
+		cancelled:         stats.cancelled,
+		invalidJSON:       stats.invalidJSON,
+		truncated:         stats.truncated,
+		redirected:        stats.redirected,
+		tooManyRedirects:  stats.tooManyRedirects,
+		firstTrySuccesses: stats.firstTrySuccesses,
+		retriedSuccesses:  stats.retriedSuccesses,
+		retries:           stats.retries,
+		statusCodes:       make(map[int]*LatencySummary, len(stats.statusCodes)),
+		errorGroups:       make(map[string]*errorGroup, len(stats.errorGroups)),
+	}
+	for code, summary := range stats.statusCodes {
+		copied := *summary
+		snapshot.statusCodes[code] = &copied
+	}
+	for key, group := range stats.errorGroups {
+		copied := *group
+		snapshot.errorGroups[key] = &copied
+	}
+	return snapshot
+}
+
+// Function to print a summary block of the test so far every interval until done is closed, for checking on a long
+// test.  Only the copy and the printing hold the output mutex.
+func printSummarySnapshots(mu *sync.Mutex, stats *testStats, url string, numThreads int, interval time.Duration,
+	done <-chan struct{}, finished chan<- struct{}) {
+	defer close(finished)
+
+	startTime := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		mu.Lock()
+		snapshot := stats.snapshot()
+		mu.Unlock()
+		elapsed := time.Since(startTime).Seconds()
+		result := snapshot.summarize(url, numThreads, elapsed)
+
+		mu.Lock()
+		fmt.Printf("Summary after %.1f s:\n", elapsed)
+		fmt.Printf("  Requests: %d - Failed: %d - Requests per second: %.2f\n", result.TotalRequests,
+			result.FailedRequests, result.RequestsPerSecond)
+		if result.TotalRequests > 0 {
+			fmt.Printf("  Average response time: %.2f ms\n", result.AverageResponseTime)
+			fmt.Printf("  Percentile response times: p50 %.2f ms - p90 %.2f ms - p95 %.2f ms - p99 %.2f ms\n",
+				result.P50ResponseTime, result.P90ResponseTime, result.P95ResponseTime, result.P99ResponseTime)
+		}
+		printStatusCodes(&result)
+		printErrorGroups(snapshot.errorGroups)
+		mu.Unlock()
+	}
+}
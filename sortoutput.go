@@ -0,0 +1,31 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import "sort"
+
+// Per-request results held back by -sortOutput until the test finishes.  Guarded by the output mutex.
+type sortedOutput struct {
+	results []requestResult
+}
+
+// Function to print the held back results in thread, iteration, and stream order
+func (output *sortedOutput) flush(cfg *testConfig) {
+	results := output.results
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].ThreadID != results[j].ThreadID {
+			return results[i].ThreadID < results[j].ThreadID
+		}
+		if results[i].Iteration != results[j].Iteration {
+			return results[i].Iteration < results[j].Iteration
+		}
+		return results[i].Stream < results[j].Stream
+	})
+
+	// The results are printed for real now
+	cfg.sortedOutput = nil
+	for i := range results {
+		printResult(&results[i], cfg)
+	}
+}
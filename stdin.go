@@ -0,0 +1,124 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request read from stdin in the -requestsFromStdin mode
+type stdinRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// Function to read newline-delimited URLs or JSON request objects and feed them to the workers.
+// The channel is bounded so the reader blocks until a worker is free.  With -randomizeHeaders the https requests are
+// skipped, since their header lines cannot be shuffled.  Closes the channel at EOF.
+func readStdinRequests(ctx context.Context, reader io.Reader, baseURL string, plainHTTP bool,
+	requests chan<- stdinRequest) {
+	defer close(requests)
+
+	scanner := bufio.NewScanner(reader)
+	// Allow request lines with large bodies
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var req stdinRequest
+		if strings.HasPrefix(line, "{") {
+			if err := json.Unmarshal([]byte(line), &req); err != nil {
+				fmt.Printf("Error: Invalid JSON request \"%s\" on stdin: %v\n", line, err)
+				continue
+			}
+		} else {
+			req.URL = line
+		}
+		if req.Method == "" {
+			req.Method = "GET"
+		}
+		// Relative paths are resolved against the URL argument
+		if strings.HasPrefix(req.URL, "/") && baseURL != "" {
+			req.URL = strings.TrimRight(baseURL, "/") + req.URL
+		}
+		if plainHTTP && isHTTPS(req.URL) {
+			fmt.Printf("Error: -randomizeHeaders cannot be used with the https request \"%s\" on stdin.\n", req.URL)
+			continue
+		}
+		select {
+		case requests <- req:
+		case <-ctx.Done():
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Printf("Error: Reading stdin failed: %v\n", err)
+	}
+}
+
+// Function to make the requests received from stdin and measure response time
+func streamData(ctx context.Context, wg *sync.WaitGroup, mu *sync.Mutex, httpClient *http.Client, stats *testStats,
+	requests <-chan stdinRequest, cfg *testConfig, threadID int) {
+	defer wg.Done()
+
+	i := 0
+	for ctx.Err() == nil {
+		var req stdinRequest
+		var ok bool
+		select {
+		case req, ok = <-requests:
+		case <-ctx.Done():
+		}
+		if !ok {
+			return
+		}
+
+		var body io.Reader
+		if req.Body != "" {
+			body = strings.NewReader(req.Body)
+		}
+
+		// Create the request structure for the httpClient
+		request, err := http.NewRequestWithContext(ctx, req.Method, req.URL, body)
+		if err != nil {
+			mu.Lock()
+			fmt.Printf("Error:  Request creation failed for thread %2d: %v\n", threadID, err)
+			mu.Unlock()
+			continue
+		}
+		for name, value := range req.Headers {
+			request.Header.Set(name, value)
+		}
+		setConnectionHeader(request, cfg.reuseConnects)
+
+		saved := stats.claimBodyBuffer(cfg)
+		result := doRequest(httpClient, request, cfg, stats, saved)
+		result.ThreadID = threadID
+		result.Iteration = i
+		saveBody(cfg, saved, threadID, i)
+
+		mu.Lock()
+		printResult(&result, cfg)
+		stats.record(&result)
+		mu.Unlock()
+
+		i++
+		if cfg.sleepTime > 0 {
+			time.Sleep(cfg.sleepTime)
+		}
+	}
+}
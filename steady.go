@@ -0,0 +1,77 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Steady-state window of the test, from when every thread has finished its ramp-up delay and warmup calls to when
+// the first thread finishes.  Guarded by the output mutex.
+type steadyWindow struct {
+	// Number of threads taking part in the window and the number that are ready
+	threads      int
+	readyThreads int
+	startTime    time.Time
+	startCount   int
+	endTime      time.Time
+	endCount     int
+	// Number of threads that have finished
+	finishedThreads int
+}
+
+// Function to mark a thread as past its ramp-up and warmup.  The window starts when the last thread is ready.
+// The caller must hold the output mutex.
+func (stats *testStats) threadReady() {
+	stats.steady.readyThreads++
+	if stats.steady.readyThreads == stats.steady.threads {
+		stats.steady.startTime = time.Now()
+		stats.steady.startCount = len(stats.responseTimes)
+	}
+}
+
+// Function to mark a thread as finished.  The window ends when the first thread finishes, since the load drops
+// after that.  The caller must hold the output mutex.
+func (stats *testStats) threadFinished() {
+	stats.steady.finishedThreads++
+	if stats.steady.endTime.IsZero() {
+		stats.steady.endTime = time.Now()
+		stats.steady.endCount = len(stats.responseTimes)
+	}
+}
+
+// Function to get the steady-state test time in seconds and requests per second.  Returns zeros if the window never
+// started, which happens when a thread finished before all the threads were ready.
+func (stats *testStats) steadyState() (float64, float64) {
+	window := &stats.steady
+	if window.startTime.IsZero() || !window.endTime.After(window.startTime) {
+		return 0, 0
+	}
+	seconds := window.endTime.Sub(window.startTime).Seconds()
+	return seconds, float64(window.endCount-window.startCount) / seconds
+}
+
+// Function to wait for the thread's ramp-up delay plus its random -startJitter offset, so threads with the same sleep
+// time do not send their requests in lockstep.  Returns false if the test is cancelled while waiting.
+func rampUpDelay(cfg *testConfig, done <-chan struct{}, threadID int, numThreads int) bool {
+	delay := time.Duration(0)
+	if cfg.rampUp > 0 {
+		delay = cfg.rampUp * time.Duration(threadID) / time.Duration(numThreads)
+	}
+	if cfg.startJitter > 0 {
+		delay += rand.N(cfg.startJitter)
+	}
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
@@ -0,0 +1,44 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Function to send the -streams concurrent requests of a thread iteration and wait for all of them.  Over HTTP/2
+// the requests share the thread's connection as separate streams.
+func fetchStreams(ctx context.Context, mu *sync.Mutex, httpClient *http.Client, stats *testStats,
+	request *http.Request, baseQuery string, cfg *testConfig, threadID int, iteration int) {
+	var streams sync.WaitGroup
+	for stream := 1; stream <= cfg.streams; stream++ {
+		// Each stream needs its own copy because preparing the request sets the query and body
+		streamRequest := request.Clone(ctx)
+		streams.Add(1)
+		go func() {
+			defer streams.Done()
+			fetchOnce(ctx, mu, httpClient, stats, streamRequest, baseQuery, cfg, threadID, iteration, stream)
+		}()
+	}
+	streams.Wait()
+}
+
+// Function to print the response time statistics for each -streams stream number
+func printStreamStats(streamStats map[int]*LatencySummary) {
+	streams := make([]int, 0, len(streamStats))
+	for stream := range streamStats {
+		streams = append(streams, stream)
+	}
+	sort.Ints(streams)
+
+	for _, stream := range streams {
+		summary := streamStats[stream]
+		fmt.Printf("Stream %3d - Count: %d - Average: %.2f ms - Min: %.2f ms - Max: %.2f ms\n", stream, summary.Count,
+			summary.AverageResponseTime, summary.MinResponseTime, summary.MaxResponseTime)
+	}
+}
@@ -0,0 +1,224 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Function to print the built-in test summary
+func printSummary(result *Result, cfg *testConfig, stats *testStats, connectionsOnly bool) {
+	fmt.Printf("Total thread count: %d\n", result.Threads)
+	fmt.Printf("Total test time: %.2f s\n", result.TotalTime)
+	timeName, countName := "response", "requests"
+	if connectionsOnly {
+		timeName, countName = "connect", "connections"
+	}
+	fmt.Printf("Average %s time: %.2f ms\n", timeName, result.AverageResponseTime)
+	fmt.Printf("Percentile %s times: p50 %.2f ms - p90 %.2f ms - p95 %.2f ms - p99 %.2f ms\n", timeName,
+		result.P50ResponseTime, result.P90ResponseTime, result.P95ResponseTime, result.P99ResponseTime)
+	if len(result.UnreliablePercentiles) > 0 {
+		fmt.Printf("Note: %d samples are too few for a reliable %s.  A pN needs at least 100/(100-N) samples.\n",
+			result.TotalRequests, strings.Join(result.UnreliablePercentiles, ", "))
+	}
+	if !connectionsOnly {
+		fmt.Printf("Response sizes: Average %.0f B - Min %.0f B - Max %.0f B - p50 %.0f B - p95 %.0f B - p99 %.0f B\n",
+			result.AverageResponseSize, result.MinResponseSize, result.MaxResponseSize, result.P50ResponseSize,
+			result.P95ResponseSize, result.P99ResponseSize)
+	}
+	if cfg.bodySizeMax > 0 {
+		fmt.Printf("Request body sizes: Average %.0f B - Min %.0f B - Max %.0f B - Response time correlation: %.2f\n",
+			result.AverageRequestBodySize, result.MinRequestBodySize, result.MaxRequestBodySize,
+			result.BodySizeLatencyCorrelation)
+	}
+	fmt.Printf("Average %s per second: %.2f\n", countName, result.RequestsPerSecond)
+	if result.SteadyStateTime > 0 {
+		fmt.Printf("Steady-state test time: %.2f s\n", result.SteadyStateTime)
+		fmt.Printf("Steady-state requests per second: %.2f\n", result.SteadyStateRequestsPerSecond)
+	}
+	if result.ApdexTarget > 0 {
+		fmt.Printf("Apdex score (%.2f ms target): %.3f\n", result.ApdexTarget, result.Apdex)
+	}
+	if result.LatencyTarget > 0 {
+		printAdaptiveSteps(result.ConcurrencyTimeline)
+		fmt.Printf("Sustained throughput at the %.2f ms target: %.2f requests per second - Concurrency: %d\n",
+			result.LatencyTarget, result.SustainedRequestsPerSecond, result.KneeConcurrency)
+	}
+	if result.MaxRPSPerThread > 0 {
+		fmt.Printf("Requests per second per thread: Average %.2f - Min %.2f - Max %.2f - Cap %.2f\n",
+			result.AverageThreadRPS, result.MinThreadRPS, result.MaxThreadRPS, result.MaxRPSPerThread)
+	}
+	if result.ScheduledWaits > 0 {
+		fmt.Printf("Scheduling delay: Average %.3f ms - Max %.3f ms - First half %.3f ms - Second half %.3f ms - "+
+			"Waits: %d\n", result.AverageSchedulingDelay, result.MaxSchedulingDelay, result.FirstHalfSchedulingDelay,
+			result.SecondHalfSchedulingDelay, result.ScheduledWaits)
+		if schedulingFellBehind(result.FirstHalfSchedulingDelay, result.SecondHalfSchedulingDelay) {
+			fmt.Println("Note: The scheduling delay grew during the test, so the tester is saturated and the request " +
+				"rate is not the server's limit.")
+		}
+	}
+	if result.ReplayLoops > 0 {
+		fmt.Printf("Replay loops: %d\n", result.ReplayLoops)
+	}
+	if result.ReplayedRequests > 0 {
+		fmt.Printf("Replay schedule lateness: Average %.2f ms - Max %.2f ms\n", result.AverageScheduleLateness,
+			result.MaxScheduleLateness)
+	}
+	if result.Interrupted {
+		fmt.Println("Test interrupted.")
+	}
+	if result.AbortReason != "" {
+		fmt.Printf("Test aborted: %s\n", result.AbortReason)
+	}
+	if stats.sla != nil {
+		fmt.Printf("SLA alerts: %d\n", result.SLAAlerts)
+	}
+	fmt.Printf("Failed requests: %d\n", result.FailedRequests)
+	if result.FailedRequests > 0 && !connectionsOnly {
+		fmt.Printf("Connection failures: %d - Failed after connecting: %d\n", result.ConnectionFailures,
+			result.FailedAfterConnecting)
+	}
+	if result.SuccessPredicate != "" {
+		fmt.Printf("Requests satisfying \"%s\": %d\n", result.SuccessPredicate, result.SatisfiedRequests)
+	}
+	if result.CancelledRequests > 0 {
+		fmt.Printf("Cancelled requests: %d\n", result.CancelledRequests)
+	}
+	if len(cfg.expectHeaders) > 0 {
+		fmt.Printf("Header check failures: %d\n", result.HeaderCheckFailures)
+	}
+	if len(cfg.jsonAssertions) > 0 {
+		fmt.Printf("JSON assertion failures: %d\n", result.JSONAssertionFailures)
+	}
+	if cfg.echo != nil {
+		fmt.Printf("Echo check (%s=%s) - Mismatches: %d - Missing: %d\n", cfg.echo.requestHeader,
+			cfg.echo.responseHeader, result.EchoMismatches, result.EchoMissing)
+	}
+	if cfg.validator != nil {
+		fmt.Printf("Validator command runs: %d - Average run time: %.2f ms - Failures: %d\n", result.ValidatorRuns,
+			result.AverageValidatorTime, result.ValidatorFailures)
+	}
+	if cfg.validateJSON {
+		fmt.Printf("Invalid JSON responses: %d\n", result.InvalidJSON)
+	}
+	if cfg.maxBodySize > 0 {
+		fmt.Printf("Truncated responses: %d\n", result.TruncatedResponses)
+	}
+	if result.RedirectedRequests > 0 || result.TooManyRedirects > 0 {
+		fmt.Printf("Redirected requests: %d\n", result.RedirectedRequests)
+		fmt.Printf("Too many redirects: %d\n", result.TooManyRedirects)
+	}
+	if cfg.token != nil {
+		fmt.Printf("Token refreshes: %d - Requests retried after a 401: %d\n", result.TokenRefreshes, result.AuthRetries)
+	}
+	if cfg.preRequest != nil {
+		fmt.Printf("Pre-request command runs: %d - Average run time: %.2f ms - Cache hits: %d\n", result.PreRequestRuns,
+			result.AveragePreRequestTime, result.PreRequestCacheHits)
+	}
+	if cfg.retries > 0 {
+		fmt.Printf("First try successes: %d\n", result.FirstTrySuccesses)
+		fmt.Printf("Retried then succeeded: %d\n", result.RetriedSuccesses)
+		fmt.Printf("Total retries: %d\n", result.Retries)
+	}
+	if !connectionsOnly {
+		printStatusCodes(result)
+	}
+	fmt.Printf("Connections opened: %d - IPv4 %d - IPv6 %d\n", result.ConnectionsOpened, result.IPv4Connections,
+		result.IPv6Connections)
+	if result.DNSLookups > 0 || result.DNSCached {
+		fmt.Printf("DNS lookups: %d\n", result.DNSLookups)
+	}
+	if result.DNSCached {
+		fmt.Printf("DNS cache hits: %d - Hit rate: %.2f%%\n", result.DNSCacheHits, result.DNSCacheHitRate)
+	}
+	if cfg.readRate > 0 {
+		fmt.Printf("Connection hold times: Total %.2f s - Average %.2f ms - Max %.2f ms\n", result.TotalHoldTime,
+			result.AverageHoldTime, result.MaxHoldTime)
+	}
+	if cfg.responseHeaderTimeout > 0 {
+		fmt.Printf("Response header timeouts: %d\n", result.ResponseHeaderTimeouts)
+	}
+	if result.LocalPortFailures > 0 {
+		fmt.Printf("Local port exhaustion failures: %d - Try -reuseConnects or more local ports.\n",
+			result.LocalPortFailures)
+	}
+	if cfg.rotateConnAfter > 0 {
+		fmt.Printf("Connection rotations: %d\n", result.ConnectionRotations)
+	}
+	if result.RequestsPerConnection > 0 {
+		fmt.Printf("Requests per connection limit: %d - Connections closed at the limit: %d - "+
+			"Server also closed: %d - Failed at the limit: %d\n", result.RequestsPerConnection, result.ConnectionsLimited,
+			result.ServerClosedAtLimit, result.FailedAtLimit)
+		if result.ServerClosedBeforeLimit > 0 {
+			fmt.Printf("Server closed connections before the limit: %d - Most requests on one: %d\n",
+				result.ServerClosedBeforeLimit, result.MaxRequestsBeforeServerClose)
+		}
+	}
+	if result.CacheHeader != "" {
+		fmt.Printf("Cache hits (%s): %d - Misses: %d - No header: %d - Hit rate: %.2f%%\n", result.CacheHeader,
+			result.CacheHits, result.CacheMisses, result.WithoutCacheHeader, result.CacheHitRate)
+	}
+	if cfg.expect100 {
+		fmt.Printf("Expect 100-continue requests: %d - Got 100 Continue: %d - Average wait: %.2f ms\n",
+			result.ExpectContinueRequests, result.Got100Continue, result.AverageContinueWait)
+	}
+	if result.ConnectionLifetime > 0 {
+		fmt.Printf("Connection lifetime: %.2f s - Connections recycled for age: %d\n", result.ConnectionLifetime,
+			result.ConnectionsRecycled)
+	}
+	if result.ClientRebuilds > 0 {
+		fmt.Printf("HTTP client rebuilds: %d\n", result.ClientRebuilds)
+	}
+	if result.TLSHandshakes > 0 {
+		fmt.Printf("TLS handshakes: %d - Resumed: %d - Average handshake time: %.2f ms\n", result.TLSHandshakes,
+			result.TLSResumedHandshakes, result.AverageTLSHandshakeTime)
+	}
+	if result.PrewarmedConnections > 0 {
+		fmt.Printf("Prewarmed connections: %d - Prewarm time: %.2f ms\n", result.PrewarmedConnections, result.PrewarmTime)
+	}
+	if !connectionsOnly {
+		if result.MaxInflight > 0 {
+			fmt.Printf("Peak concurrent requests: %d - Cap: %d\n", result.PeakConcurrency, result.MaxInflight)
+		} else {
+			fmt.Printf("Peak concurrent requests: %d\n", result.PeakConcurrency)
+		}
+	}
+
+	if result.PeakHeapBytes > 0 {
+		fmt.Printf("Tester GC runs: %d - GC pause time: %.2f ms - Peak heap: %.2f MB\n", result.GCCount,
+			result.GCPauseTime, float64(result.PeakHeapBytes)/(1024*1024))
+	}
+
+	if cfg.trace {
+		printPhaseBreakdown(stats.phaseTotals, stats.traced)
+	}
+	if cfg.rpsTimeline {
+		printThroughputTimeline(result.RequestsPerSecondTimeline)
+	}
+	if len(cfg.targets) > 0 {
+		printTargetStats(cfg.targets, stats.targetStats, stats.targetFailures, stats.targetTimeouts)
+	}
+	if cfg.burst > 0 {
+		printBurstStats(&stats.burstFirst, &stats.burstRest)
+	}
+	printBackendStats(stats.backendStats)
+	printStreamStats(stats.streamStats)
+	if len(result.ChainSteps) > 0 {
+		printChainSteps(result.ChainSteps, result.ChainSkipped)
+	}
+	printQueryStats(stats.queryStats)
+	printKeyedStats("URL", stats.urlStats, stats.urlFailures)
+	printBodyFileFailures(stats.bodyFileFailures)
+	printErrorGroups(stats.errorGroups)
+	printSLOs(result.SLOs)
+}
+
+// Function to print the response time statistics for the first request of each burst and the rest of the burst
+func printBurstStats(first *LatencySummary, rest *LatencySummary) {
+	fmt.Printf("Burst first requests - Count: %d - Average: %.2f ms - Min: %.2f ms - Max: %.2f ms\n", first.Count,
+		first.AverageResponseTime, first.MinResponseTime, first.MaxResponseTime)
+	fmt.Printf("In-burst requests    - Count: %d - Average: %.2f ms - Min: %.2f ms - Max: %.2f ms\n", rest.Count,
+		rest.AverageResponseTime, rest.MinResponseTime, rest.MaxResponseTime)
+}
@@ -0,0 +1,68 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Column names of the summary CSV row in the order written by summaryCSVRecord
+var summaryCSVHeader = []string{"timestamp", "label", "url", "threads", "requests", "failedRequests",
+	"errorRatePercent", "averageMs", "p50Ms", "p95Ms", "p99Ms", "requestsPerSecond", "totalTimeSec"}
+
+// Function to format the test summary as a single CSV row stamped with the end of the test
+func (result *Result) summaryCSVRecord(timestamp time.Time) []string {
+	errorRate := 0.0
+	if result.TotalRequests > 0 {
+		errorRate = float64(result.FailedRequests) * 100 / float64(result.TotalRequests)
+	}
+	return []string{
+		timestamp.Format(time.RFC3339),
+		result.Label,
+		result.URL,
+		strconv.Itoa(result.Threads),
+		strconv.Itoa(result.TotalRequests),
+		strconv.Itoa(result.FailedRequests),
+		strconv.FormatFloat(errorRate, 'f', 3, 64),
+		strconv.FormatFloat(result.AverageResponseTime, 'f', 3, 64),
+		strconv.FormatFloat(result.P50ResponseTime, 'f', 3, 64),
+		strconv.FormatFloat(result.P95ResponseTime, 'f', 3, 64),
+		strconv.FormatFloat(result.P99ResponseTime, 'f', 3, 64),
+		strconv.FormatFloat(result.RequestsPerSecond, 'f', 2, 64),
+		strconv.FormatFloat(result.TotalTime, 'f', 3, 64),
+	}
+}
+
+// Function to write the summary CSV row, after the header when it is set
+func writeSummaryCSV(writer io.Writer, result *Result, timestamp time.Time, header bool) error {
+	out := csv.NewWriter(writer)
+	if header {
+		_ = out.Write(summaryCSVHeader)
+	}
+	_ = out.Write(result.summaryCSVRecord(timestamp))
+	out.Flush()
+	return out.Error()
+}
+
+// Function to append the summary CSV row to the -summaryCSV file so several runs build up one spreadsheet.  The header
+// is written when the file is new or empty.
+func appendSummaryCSV(fileName string, result *Result, timestamp time.Time) error {
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	header := false
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		header = true
+	}
+	err = writeSummaryCSV(file, result, timestamp, header)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,300 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Endpoint from a -target file.  Each request picks a target at random in proportion to its weight.
+type Target struct {
+	Method  string
+	URL     string
+	Headers http.Header
+	Body    []byte
+	Weight  int
+	// Expected response status code, zero to accept any status
+	ExpectStatus int
+	// Request timeout, zero to use the -requestTimeOut
+	Timeout time.Duration
+}
+
+// Function to get the label used for the target in the summary
+func (target *Target) Name() string {
+	return target.Method + " " + target.URL
+}
+
+// Function to load a -target file.  Each line is "METHOD URL [weight=N] [status=N] [timeout=N]
+// [header=Name:Value]... [body=text|body=@file]".  The timeout is in milliseconds or has a unit, like "2s".  Values
+// with spaces are double-quoted.  Blank lines and lines starting with "#" are skipped.  Relative URLs are resolved
+// against the base URL.  A file name ending in ".json" is a JSON array of targets instead.
+func loadTargetFile(fileName string, baseURL string) ([]Target, error) {
+	if strings.HasSuffix(fileName, ".json") {
+		return loadTargetJSON(fileName, baseURL)
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var targets []Target
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		target, err := parseTargetLine(line, baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		targets = append(targets, target)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets")
+	}
+	return targets, nil
+}
+
+// Function to parse a single -target file line
+func parseTargetLine(line string, baseURL string) (Target, error) {
+	target := Target{Headers: make(http.Header), Weight: 1}
+
+	fields, err := splitQuoted(line)
+	if err != nil {
+		return target, err
+	}
+	if len(fields) < 2 {
+		return target, fmt.Errorf("expected \"METHOD URL\" but found \"%s\"", line)
+	}
+	target.Method = strings.ToUpper(fields[0])
+	if err := target.setURL(fields[1], baseURL); err != nil {
+		return target, err
+	}
+
+	for _, field := range fields[2:] {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return target, fmt.Errorf("expected \"key=value\" but found \"%s\"", field)
+		}
+		switch key {
+		case "weight":
+			target.Weight, err = strconv.Atoi(value)
+			if err != nil || target.Weight < 0 {
+				return target, fmt.Errorf("\"%s\" is not a valid weight", value)
+			}
+		case "status":
+			target.ExpectStatus, err = strconv.Atoi(value)
+			if err != nil {
+				return target, fmt.Errorf("\"%s\" is not a valid status code", value)
+			}
+		case "timeout":
+			// Milliseconds without a unit
+			if _, numberErr := strconv.Atoi(value); numberErr == nil {
+				value += "ms"
+			}
+			target.Timeout, err = time.ParseDuration(value)
+			if err != nil || target.Timeout <= 0 {
+				return target, fmt.Errorf("\"%s\" is not a valid timeout", value)
+			}
+		case "header":
+			name, headerValue, found := strings.Cut(value, ":")
+			if !found {
+				return target, fmt.Errorf("expected \"Name: value\" but found \"%s\"", value)
+			}
+			target.Headers.Add(strings.TrimSpace(name), strings.TrimSpace(headerValue))
+		case "body":
+			if strings.HasPrefix(value, "@") {
+				target.Body, err = os.ReadFile(value[1:])
+				if err != nil {
+					return target, err
+				}
+			} else {
+				target.Body = []byte(value)
+			}
+		default:
+			return target, fmt.Errorf("\"%s\" is not a valid target key", key)
+		}
+	}
+	return target, nil
+}
+
+// Function to set the target URL, resolving a relative URL against the base URL
+func (target *Target) setURL(url string, baseURL string) error {
+	target.URL = url
+	if strings.HasPrefix(target.URL, "/") && baseURL != "" {
+		target.URL = strings.TrimRight(baseURL, "/") + target.URL
+	}
+	if !strings.HasPrefix(target.URL, "http") {
+		return fmt.Errorf("\"%s\" is not a valid URL", target.URL)
+	}
+	return nil
+}
+
+// Target in a JSON -target file.  The body is a JSON string sent as text or any other JSON value sent as JSON.
+type targetEntry struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Weight    *int              `json:"weight"`
+	Status    int               `json:"status"`
+	TimeoutMs int               `json:"timeoutMs"`
+	Headers   map[string]string `json:"headers"`
+	Body      json.RawMessage   `json:"body"`
+}
+
+// Function to load a JSON -target file.  The method defaults to GET, or POST with a body, and the weight to 1.
+func loadTargetJSON(fileName string, baseURL string) ([]Target, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var entries []targetEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no targets")
+	}
+
+	targets := make([]Target, len(entries))
+	for i, entry := range entries {
+		target := Target{Method: strings.ToUpper(entry.Method), Headers: make(http.Header), Weight: 1,
+			ExpectStatus: entry.Status, Timeout: time.Duration(entry.TimeoutMs) * time.Millisecond}
+		if err := target.setURL(entry.URL, baseURL); err != nil {
+			return nil, fmt.Errorf("target %d: %w", i+1, err)
+		}
+		if entry.Weight != nil {
+			target.Weight = *entry.Weight
+		}
+		if target.Weight < 0 || entry.TimeoutMs < 0 {
+			return nil, fmt.Errorf("target %d: the weight and timeout cannot be negative", i+1)
+		}
+		for name, value := range entry.Headers {
+			target.Headers.Set(name, value)
+		}
+		if len(entry.Body) > 0 && string(entry.Body) != "null" {
+			var text string
+			if json.Unmarshal(entry.Body, &text) == nil {
+				target.Body = []byte(text)
+			} else {
+				target.Body = entry.Body
+				if target.Headers.Get("Content-Type") == "" {
+					target.Headers.Set("Content-Type", "application/json")
+				}
+			}
+		}
+		if target.Method == "" {
+			target.Method = "GET"
+			if target.Body != nil {
+				target.Method = "POST"
+			}
+		}
+		targets[i] = target
+	}
+	return targets, nil
+}
+
+// Function to split a line on whitespace, keeping double-quoted text together
+func splitQuoted(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	inField, inQuotes := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			inField = true
+		case !inQuotes && (r == ' ' || r == '\t'):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in \"%s\"", line)
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// Weighted random selection over the targets
+type targetPicker struct {
+	targets []Target
+	// Running total of the weights, used to binary search a random value
+	cumulative []int
+}
+
+// Function to create a picker for the targets.  Returns an error when all the weights are zero.
+func newTargetPicker(targets []Target) (*targetPicker, error) {
+	picker := &targetPicker{targets: targets, cumulative: make([]int, len(targets))}
+	total := 0
+	for i := range targets {
+		total += targets[i].Weight
+		picker.cumulative[i] = total
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("the target weights add up to zero")
+	}
+	return picker, nil
+}
+
+// Function to pick a target in proportion to the weights.  Returns the target index.
+func (picker *targetPicker) pick() int {
+	value := rand.IntN(picker.cumulative[len(picker.cumulative)-1])
+	return sort.SearchInts(picker.cumulative, value+1)
+}
+
+// Function to create the request for a target.  The body reader is regenerated for every request.
+func (target *Target) newRequest(ctx context.Context, cfg *testConfig) (*http.Request, error) {
+	var body io.Reader
+	if target.Body != nil {
+		body = bytes.NewReader(target.Body)
+	}
+	request, err := http.NewRequestWithContext(ctx, target.Method, target.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range target.Headers {
+		request.Header[name] = values
+	}
+	setConnectionHeader(request, cfg.reuseConnects)
+	return request, nil
+}
+
+// Function to print the response time statistics and failures for each target in file order
+func printTargetStats(targets []Target, targetStats map[*Target]*LatencySummary, targetFailures map[*Target]int,
+	targetTimeouts map[*Target]int) {
+	for i := range targets {
+		summary, ok := targetStats[&targets[i]]
+		if !ok {
+			summary = &LatencySummary{}
+		}
+		fmt.Printf("Target %s - Count: %d - Failed: %d - Timeouts: %d - Average: %.2f ms - Min: %.2f ms - Max: %.2f ms\n",
+			targets[i].Name(), summary.Count, targetFailures[&targets[i]], targetTimeouts[&targets[i]],
+			summary.AverageResponseTime, summary.MinResponseTime, summary.MaxResponseTime)
+	}
+}
@@ -0,0 +1,37 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// Response body reader that reads no faster than -readRate bytes per second, like a slow client
+type throttledReader struct {
+	reader    io.Reader
+	rate      int64
+	startTime time.Time
+	total     int64
+}
+
+// Function to wrap a response body with a reader limited to the rate in bytes per second
+func newThrottledReader(reader io.Reader, rate int64) *throttledReader {
+	return &throttledReader{reader: reader, rate: rate, startTime: time.Now()}
+}
+
+// Function to read at most a tenth of a second of data and then wait until the bytes read so far are due
+func (throttled *throttledReader) Read(buffer []byte) (int, error) {
+	chunk := max(throttled.rate/10, 1)
+	if int64(len(buffer)) > chunk {
+		buffer = buffer[:chunk]
+	}
+	n, err := throttled.reader.Read(buffer)
+	throttled.total += int64(n)
+	due := time.Duration(float64(throttled.total) / float64(throttled.rate) * float64(time.Second))
+	if wait := due - time.Since(throttled.startTime); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
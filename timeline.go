@@ -0,0 +1,62 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Completed requests in each second of the test for -rpsTimeline.  Guarded by the output mutex.  Holds one count
+// per second of the test.
+type throughputTimeline struct {
+	startTime time.Time
+	counts    []int
+}
+
+// Function to count a request completed at the time
+func (timeline *throughputTimeline) add(now time.Time) {
+	second := int(now.Sub(timeline.startTime) / time.Second)
+	if second < 0 {
+		return
+	}
+	for len(timeline.counts) <= second {
+		timeline.counts = append(timeline.counts, 0)
+	}
+	timeline.counts[second]++
+}
+
+// Function to print the requests per second, ten seconds per line.  The last second is usually partial.
+func printThroughputTimeline(counts []int) {
+	fmt.Println("Requests per second timeline:")
+	for start := 0; start < len(counts); start += 10 {
+		end := min(start+10, len(counts))
+		values := make([]string, 0, end-start)
+		for _, count := range counts[start:end] {
+			values = append(values, fmt.Sprintf("%6d", count))
+		}
+		fmt.Printf("  %-10s %s\n", fmt.Sprintf("%d-%ds:", start, end-1), strings.Join(values, " "))
+	}
+}
+
+// Function to write the requests per second as CSV lines of the second of the test and the request count
+func writeThroughputTimeline(fileName string, counts []int) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	fmt.Fprintln(writer, "second,requests")
+	for second, count := range counts {
+		fmt.Fprintf(writer, "%d,%d\n", second, count)
+	}
+	if err := writer.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,64 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Function to build the certificate pool of the -caCert files, starting from the system pool when system is true
+func loadCertPool(fileNames []string, system bool) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if system {
+		var err error
+		if pool, err = x509.SystemCertPool(); err != nil {
+			return nil, fmt.Errorf("loading the system certificates failed: %w", err)
+		}
+	}
+	for _, fileName := range fileNames {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates could be parsed from \"%s\"", fileName)
+		}
+	}
+	return pool, nil
+}
+
+// Function to add the subject, issuer, and expiry of the server certificate to a -verifyTLS certificate error.
+// Returns other errors unchanged.
+func describeTLSError(err error) error {
+	var cert *x509.Certificate
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	switch {
+	case errors.As(err, &verifyErr) && len(verifyErr.UnverifiedCertificates) > 0:
+		cert = verifyErr.UnverifiedCertificates[0]
+	case errors.As(err, &authorityErr):
+		cert = authorityErr.Cert
+	case errors.As(err, &invalidErr):
+		cert = invalidErr.Cert
+	case errors.As(err, &hostnameErr):
+		cert = hostnameErr.Certificate
+	}
+	if cert == nil {
+		return err
+	}
+
+	expiry := "expires"
+	if time.Now().After(cert.NotAfter) {
+		expiry = "expired"
+	}
+	return fmt.Errorf("%w (certificate subject \"%s\", issuer \"%s\", %s %s)", err, cert.Subject, cert.Issuer, expiry,
+		cert.NotAfter.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,158 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Time before the token expiry when the token is refreshed.  Short-lived tokens are refreshed at half their lifetime.
+const tokenRefreshMargin = 30 * time.Second
+
+// Maximum time a -tokenCommand may run
+const tokenCommandTimeout = 30 * time.Second
+
+// Bearer token shared by all the threads and refreshed before it expires
+type bearerToken struct {
+	mu sync.Mutex
+	// Function to get a new token and how long it is valid for, zero for no expiry
+	fetch func() (string, time.Duration, error)
+	token string
+	// Time to refresh the token at, zero to keep it until it is rejected
+	refreshAt time.Time
+	refreshes int
+}
+
+// Function to get the current token, refreshing it if it is near expiry
+func (t *bearerToken) get() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == "" || (!t.refreshAt.IsZero() && !time.Now().Before(t.refreshAt)) {
+		if err := t.refreshLocked(); err != nil {
+			return "", err
+		}
+	}
+	return t.token, nil
+}
+
+// Function to refresh the token after it was rejected.  The token is only refreshed if it is still the rejected
+// token, so a burst of rejected requests across the threads causes a single refresh.
+func (t *bearerToken) refresh(rejected string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == rejected {
+		if err := t.refreshLocked(); err != nil {
+			return "", err
+		}
+	}
+	return t.token, nil
+}
+
+// Function to fetch a new token.  The caller must hold the token mutex.
+func (t *bearerToken) refreshLocked() error {
+	token, lifetime, err := t.fetch()
+	if err != nil {
+		return err
+	}
+	if t.token != "" {
+		t.refreshes++
+	}
+	t.token = token
+	t.refreshAt = time.Time{}
+	if lifetime > 0 {
+		t.refreshAt = time.Now().Add(lifetime - min(tokenRefreshMargin, lifetime/2))
+	}
+	return nil
+}
+
+// Function to get the number of times the token was refreshed after the first fetch
+func (t *bearerToken) refreshCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.refreshes
+}
+
+// Function to create a token fetch for the OAuth2 client credentials grant
+func oauthClientCredentials(httpClient *http.Client, tokenURL string, clientID string, clientSecret string,
+	scopes string) func() (string, time.Duration, error) {
+	return func() (string, time.Duration, error) {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		if scopes != "" {
+			form.Set("scope", scopes)
+		}
+		request, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", 0, err
+		}
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Accept", "application/json")
+		request.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+
+		resp, err := httpClient.Do(request)
+		if err != nil {
+			return "", 0, err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+		if err != nil {
+			return "", 0, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return "", 0, fmt.Errorf("token endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+
+		var token struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+		}
+		if err := json.Unmarshal(body, &token); err != nil {
+			return "", 0, fmt.Errorf("invalid token response: %w", err)
+		}
+		if token.AccessToken == "" {
+			return "", 0, fmt.Errorf("token response has no access_token")
+		}
+		return token.AccessToken, time.Duration(token.ExpiresIn) * time.Second, nil
+	}
+}
+
+// Function to create a token fetch that runs a command, like "gcloud auth print-access-token", and uses its trimmed
+// output as the token for the ttl, zero to keep it until it is rejected
+func commandToken(command string, ttl time.Duration) (func() (string, time.Duration, error), error) {
+	args, err := splitQuoted(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return func() (string, time.Duration, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			if message := strings.TrimSpace(stderr.String()); message != "" {
+				return "", 0, fmt.Errorf("%w: %s", err, message)
+			}
+			return "", 0, err
+		}
+		token := strings.TrimSpace(string(output))
+		if token == "" {
+			return "", 0, fmt.Errorf("the command printed no token")
+		}
+		return token, ttl, nil
+	}, nil
+}
@@ -0,0 +1,139 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sync"
+	"time"
+)
+
+// Phases of a request measured by -trace, in the order they happen
+const (
+	phaseDNS = iota
+	phaseConnect
+	phaseTLS
+	phaseSend
+	phaseWait
+	phaseTransfer
+	// Time not in the other phases, like waiting for a pooled connection
+	phaseOther
+	phaseCount
+)
+
+// Names of the -trace phases in the summary and the folded stacks
+var phaseNames = [phaseCount]string{"dns", "connect", "tls", "send", "wait", "transfer", "other"}
+
+// Phase timing of a single attempt.  Has its own mutex because the dial hooks can run on the transport goroutines.
+type phaseTrace struct {
+	mu         sync.Mutex
+	starts     [phaseCount]time.Time
+	durations  [phaseCount]time.Duration
+	gotConn    time.Time
+	firstBytes time.Time
+}
+
+// Function to start a phase
+func (trace *phaseTrace) start(phase int) {
+	trace.mu.Lock()
+	trace.starts[phase] = time.Now()
+	trace.mu.Unlock()
+}
+
+// Function to end a phase and add its time.  A phase that did not start adds nothing.
+func (trace *phaseTrace) end(phase int) {
+	trace.mu.Lock()
+	if !trace.starts[phase].IsZero() {
+		trace.durations[phase] += time.Since(trace.starts[phase])
+		trace.starts[phase] = time.Time{}
+	}
+	trace.mu.Unlock()
+}
+
+// Function to add the phase hooks to the request.  A redirected request adds the phases of every hop.
+func (trace *phaseTrace) withTrace(request *http.Request) *http.Request {
+	clientTrace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { trace.start(phaseDNS) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { trace.end(phaseDNS) },
+		ConnectStart:      func(string, string) { trace.start(phaseConnect) },
+		ConnectDone:       func(string, string, error) { trace.end(phaseConnect) },
+		TLSHandshakeStart: func() { trace.start(phaseTLS) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { trace.end(phaseTLS) },
+		GotConn: func(httptrace.GotConnInfo) {
+			trace.start(phaseSend)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			trace.end(phaseSend)
+			trace.start(phaseWait)
+		},
+		GotFirstResponseByte: func() {
+			trace.end(phaseWait)
+			trace.mu.Lock()
+			trace.firstBytes = time.Now()
+			trace.mu.Unlock()
+		},
+	}
+	return request.WithContext(httptrace.WithClientTrace(request.Context(), clientTrace))
+}
+
+// Function to finish the trace when the body is read.  Returns the phase times in milliseconds, with the rest of the
+// total time as the other phase.
+func (trace *phaseTrace) finish(startTime time.Time) [phaseCount]float64 {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	now := time.Now()
+	if !trace.firstBytes.IsZero() {
+		trace.durations[phaseTransfer] = now.Sub(trace.firstBytes)
+	}
+	var phases [phaseCount]float64
+	other := now.Sub(startTime)
+	for phase := 0; phase < phaseOther; phase++ {
+		phases[phase] = milliseconds(trace.durations[phase])
+		other -= trace.durations[phase]
+	}
+	phases[phaseOther] = milliseconds(max(other, 0))
+	return phases
+}
+
+// Function to print the average time of each phase and its share of the total time
+func printPhaseBreakdown(totals [phaseCount]float64, count int) {
+	if count == 0 {
+		return
+	}
+	var total float64
+	for _, phaseTotal := range totals {
+		total += phaseTotal
+	}
+	fmt.Println("Latency breakdown (average per request):")
+	for phase, phaseTotal := range totals {
+		share := 0.0
+		if total > 0 {
+			share = phaseTotal / total * 100
+		}
+		fmt.Printf("  %-10s %10.3f ms %6.1f%%\n", phaseNames[phase], phaseTotal/float64(count), share)
+	}
+}
+
+// Function to write the total phase times as folded stacks in microseconds, one "request;phase value" line a phase,
+// for flame graph and stacked bar tools
+func writeFoldedPhases(fileName string, totals [phaseCount]float64) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for phase, phaseTotal := range totals {
+		fmt.Fprintf(writer, "request;%s %d\n", phaseNames[phase], int64(phaseTotal*1000))
+	}
+	if err := writer.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,55 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Transport that replaces its connection pool with a fresh copy of the base transport after a burst of consecutive
+// transport errors, so a pool in a bad state does not fail the rest of a long test
+type rebuildingTransport struct {
+	base    *http.Transport
+	current atomic.Pointer[http.Transport]
+	// Number of consecutive errors that triggers a rebuild
+	maxErrors int64
+	errors    atomic.Int64
+	rebuilds  atomic.Int64
+}
+
+// Function to wrap a transport so it is rebuilt after maxErrors consecutive transport errors
+func newRebuildingTransport(base *http.Transport, maxErrors int) *rebuildingTransport {
+	transport := &rebuildingTransport{base: base, maxErrors: int64(maxErrors)}
+	transport.current.Store(base)
+	return transport
+}
+
+// Function to send the request with the current transport and count the consecutive errors
+func (transport *rebuildingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	current := transport.current.Load()
+	resp, err := current.RoundTrip(request)
+	if err == nil {
+		transport.errors.Store(0)
+		return resp, nil
+	}
+	// Requests cancelled by the test stopping say nothing about the connection pool
+	if request.Context().Err() != nil {
+		return resp, err
+	}
+	if transport.errors.Add(1) >= transport.maxErrors {
+		// Only the first thread to see the burst swaps the transport
+		if transport.current.CompareAndSwap(current, transport.base.Clone()) {
+			transport.errors.Store(0)
+			transport.rebuilds.Add(1)
+			current.CloseIdleConnections()
+		}
+	}
+	return resp, err
+}
+
+// Function to close the idle connections of the current transport
+func (transport *rebuildingTransport) CloseIdleConnections() {
+	transport.current.Load().CloseIdleConnections()
+}
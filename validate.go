@@ -0,0 +1,114 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Error for a response body that failed the -validateJSON check
+var errInvalidJSON = errors.New("response body is not valid JSON")
+
+// Error for a response that failed an -expectHeader check
+var errHeaderMismatch = errors.New("response header check failed")
+
+// Response header required by -expectHeader
+type headerCheck struct {
+	name  string
+	value string
+	// Only check that the header is present, for the "*" value
+	anyValue bool
+}
+
+// Function to parse an -expectHeader value like "Cache-Control: no-store" or "X-Frame-Options: *"
+func parseHeaderCheck(value string) (headerCheck, error) {
+	name, expected, found := strings.Cut(value, ":")
+	name, expected = strings.TrimSpace(name), strings.TrimSpace(expected)
+	if !found || name == "" {
+		return headerCheck{}, fmt.Errorf("expected \"Name: value\"")
+	}
+	return headerCheck{name: name, value: expected, anyValue: expected == "*"}, nil
+}
+
+// Function to check the response headers.  Returns an error naming the first header that is missing or has none of
+// the expected value.
+func checkHeaders(checks []headerCheck, header http.Header) error {
+	for _, check := range checks {
+		values := header.Values(check.name)
+		if len(values) == 0 {
+			return fmt.Errorf("%w: %s is missing", errHeaderMismatch, check.name)
+		}
+		if check.anyValue || slices.Contains(values, check.value) {
+			continue
+		}
+		return fmt.Errorf("%w: %s is \"%s\", expected \"%s\"", errHeaderMismatch, check.name,
+			strings.Join(values, ", "), check.value)
+	}
+	return nil
+}
+
+// Error for a response that does not satisfy the -success expression
+var errNotSatisfied = errors.New("response does not satisfy")
+
+// Error for a response status that does not match the expected status
+var errUnexpectedStatus = errors.New("unexpected status")
+
+// Function to fail a successful request if the status does not match the expected status.  Zero expects any status.
+func checkStatus(result *requestResult, expectStatus int) {
+	if result.err != nil || expectStatus == 0 || result.StatusCode == expectStatus {
+		return
+	}
+	result.err = fmt.Errorf("%w %d, expected %d", errUnexpectedStatus, result.StatusCode, expectStatus)
+	result.Error = result.err.Error()
+}
+
+// Function to check whether a request failed by timing out, from the client timeout or the request context deadline
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// Function to check whether a request failed waiting for the response headers past the -responseHeaderTimeout.  The
+// transport error has no type of its own, so it is matched by its message.
+func isHeaderTimeout(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "timeout awaiting response headers")
+}
+
+// Function to check the final response against the configured validations and set whether it satisfied the success
+// predicate.  Returns nil when the response passes.
+func validateResponse(cfg *testConfig, result *requestResult, body *bytes.Buffer) error {
+	if cfg.validateJSON && !json.Valid(body.Bytes()) {
+		return errInvalidJSON
+	}
+	if err := checkHeaders(cfg.expectHeaders, result.header); err != nil {
+		return err
+	}
+	var data []byte
+	if body != nil {
+		data = body.Bytes()
+	}
+	if len(cfg.jsonAssertions) > 0 {
+		if err := checkJSONAssertions(cfg.jsonAssertions, data); err != nil {
+			return err
+		}
+	}
+	if cfg.validator != nil {
+		if err := cfg.validator.check(result, data); err != nil {
+			return err
+		}
+	}
+	result.satisfied = cfg.success.eval(result, data)
+	if cfg.successRequired && !result.satisfied {
+		return fmt.Errorf("%w \"%s\"", errNotSatisfied, cfg.success.text)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// --------------------------------------------------------------
+// Created by A. Kevin Bailey on 8/10/2024 under a GPL3.0 license
+// --------------------------------------------------------------
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error for a response that the -validatorCommand rejected
+var errValidatorFailed = errors.New("validator command failed")
+
+// Most bytes of the validator output kept in the failure message
+const validatorMessageLimit = 200
+
+// Command that checks each response for -validatorCommand.  The response body is sent on stdin and a non-zero exit
+// fails the request.
+type responseValidator struct {
+	args    []string
+	timeout time.Duration
+	// Slot for each validator allowed to run at once, so the threads do not start a process each at the same time
+	slots chan struct{}
+	// Number of validator runs and their total time
+	mu      sync.Mutex
+	runs    int
+	runTime time.Duration
+}
+
+// Function to create a validator for a command line that runs up to the number of workers at once
+func newResponseValidator(command string, timeout time.Duration, workers int) (*responseValidator, error) {
+	args, err := splitQuoted(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return &responseValidator{args: args, timeout: timeout, slots: make(chan struct{}, workers)}, nil
+}
+
+// Function to run the command for a response with the body on stdin and the request details in the environment.
+// Waits for a free worker first.  Returns an error with the start of the command output when the command fails.
+func (validator *responseValidator) check(result *requestResult, body []byte) error {
+	validator.slots <- struct{}{}
+	defer func() { <-validator.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), validator.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, validator.args[0], validator.args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"APITESTER_URL="+result.URL,
+		"APITESTER_STATUS="+strconv.Itoa(result.StatusCode),
+		"APITESTER_RESPONSE_TIME="+strconv.FormatFloat(result.ResponseTime, 'f', 3, 64))
+	cmd.Stdin = bytes.NewReader(body)
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+
+	startTime := time.Now()
+	err := cmd.Run()
+	runTime := time.Since(startTime)
+	validator.mu.Lock()
+	validator.runs++
+	validator.runTime += runTime
+	validator.mu.Unlock()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		err = fmt.Errorf("timed out after %v", validator.timeout)
+	}
+	message := strings.TrimSpace(output.String())
+	if len(message) > validatorMessageLimit {
+		message = message[:validatorMessageLimit] + "..."
+	}
+	if message != "" {
+		return fmt.Errorf("%w: %v: %s", errValidatorFailed, err, message)
+	}
+	return fmt.Errorf("%w: %v", errValidatorFailed, err)
+}
+
+// Function to get the number of validator runs and their average time in milliseconds
+func (validator *responseValidator) summary() (int, float64) {
+	validator.mu.Lock()
+	defer validator.mu.Unlock()
+	if validator.runs == 0 {
+		return 0, 0
+	}
+	return validator.runs, milliseconds(validator.runTime) / float64(validator.runs)
+}